@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/publisher"
+)
+
+const (
+	defaultAPICacheSeconds = 300
+	maxAPICacheEntries     = 256
+
+	projectsPath = "/projects/"
+	notesSuffix  = "/notes"
+	bearerPrefix = "Bearer "
+	cacheHeader  = "X-Cache"
+)
+
+var formatContentTypes = map[string]string{
+	publisher.FormatMarkdown: "text/markdown; charset=utf-8",
+	publisher.FormatHTML:     "text/html; charset=utf-8",
+	publisher.FormatJSON:     "application/json",
+}
+
+type notesKey struct {
+	projectID string
+	from      string
+	to        string
+}
+
+type cachedNote struct {
+	note      app.ReleaseNote
+	expiresAt time.Time
+}
+
+// notesAPI generates the release notes between two tags without publishing
+// them, the notes are cached per project and tag pair. It reads any project
+// with the GitLab token, so it requires its own token.
+type notesAPI struct {
+	env      envConfig
+	token    string
+	generate func(env envConfig, tagName, previousTagName string) (app.ReleaseNote, error)
+
+	mu    sync.Mutex
+	cache map[notesKey]cachedNote
+	ttl   time.Duration
+}
+
+func newNotesAPI(env envConfig) *notesAPI {
+	cacheSeconds := env.APICacheSeconds
+	if cacheSeconds == 0 {
+		cacheSeconds = defaultAPICacheSeconds
+	}
+	return &notesAPI{
+		env:   env,
+		token: env.APIToken,
+		generate: func(env envConfig, tagName, previousTagName string) (app.ReleaseNote, error) {
+			// The cached note is served in every format, the JSON one links the release.
+			g, err := generateReleaseNote(env, tagName, previousTagName, true)
+			return g.note, err
+		},
+		cache: make(map[notesKey]cachedNote),
+		ttl:   time.Duration(cacheSeconds) * time.Second,
+	}
+}
+
+// ServeHTTP serves GET /projects/{id}/notes?from=&to=&format=, the id is the
+// project id or its URL-encoded path. The note of the latest tag is served
+// without to, and from defaults to the tag preceding to.
+func (a *notesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, statusResponse{Status: "error", Message: "Method not allowed."})
+		return
+	}
+	if a.token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(bearerPrefix+a.token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, statusResponse{Status: "error", Message: "Invalid token."})
+		return
+	}
+
+	projectID, ok := notesProjectID(r.URL)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, statusResponse{Status: "error", Message: "Not found."})
+		return
+	}
+
+	query := r.URL.Query()
+	key := notesKey{projectID: projectID, from: query.Get("from"), to: query.Get("to")}
+	format := query.Get("format")
+	if err := publisher.ValidateFormat(format); err != nil {
+		writeJSON(w, http.StatusBadRequest, statusResponse{Status: "error", Message: err.Error()})
+		return
+	}
+	if key.from != "" && key.to == "" {
+		writeJSON(w, http.StatusBadRequest, statusResponse{Status: "error", Message: "The from parameter requires the to parameter."})
+		return
+	}
+
+	note, cached, err := a.note(key)
+	if err != nil {
+		slog.Error("Notes failed", "project", key.projectID, "from", key.from, "to", key.to, "error", err)
+		writeJSON(w, errorStatus(err), statusResponse{Status: "error", Message: err.Error()})
+		return
+	}
+
+	body, err := publisher.Render(note, format)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, statusResponse{Status: "error", Message: err.Error()})
+		return
+	}
+	if format == "" {
+		format = publisher.FormatMarkdown
+	}
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	if cached {
+		w.Header().Set(cacheHeader, "HIT")
+	} else {
+		w.Header().Set(cacheHeader, "MISS")
+	}
+	_, _ = w.Write(body)
+}
+
+// note returns the cached note of the key, or generates it.
+func (a *notesAPI) note(key notesKey) (app.ReleaseNote, bool, error) {
+	now := time.Now()
+	a.mu.Lock()
+	entry, exists := a.cache[key]
+	a.mu.Unlock()
+	if exists && now.Before(entry.expiresAt) {
+		return entry.note, true, nil
+	}
+
+	env := a.env
+	env.ProjectID = key.projectID
+	note, err := a.generate(env, key.to, key.from)
+	if err != nil || a.ttl <= 0 {
+		return note, false, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, e := range a.cache {
+		if !now.Before(e.expiresAt) {
+			delete(a.cache, k)
+		}
+	}
+	if len(a.cache) < maxAPICacheEntries {
+		a.cache[key] = cachedNote{note: note, expiresAt: now.Add(a.ttl)}
+	}
+	return note, false, nil
+}
+
+// notesProjectID returns the project of a /projects/{id}/notes path.
+func notesProjectID(u *url.URL) (string, bool) {
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, projectsPath) || !strings.HasSuffix(path, notesSuffix) {
+		return "", false
+	}
+	escaped := strings.TrimSuffix(strings.TrimPrefix(path, projectsPath), notesSuffix)
+	projectID, err := url.PathUnescape(escaped)
+	if err != nil || projectID == "" {
+		return "", false
+	}
+	return projectID, true
+}
+
+// errorStatus classifies an error of the generation like exitCode, a missing
+// project or tag is not found even when it is a config error.
+func errorStatus(err error) int {
+	code := errorCode(err)
+	switch {
+	case hasBehavior(err, errors.IsNotFound):
+		return http.StatusNotFound
+	case code == invalidConfigCode:
+		return http.StatusInternalServerError
+	case code == app.InvalidTagRangeCode, hasBehavior(err, errors.IsInvalid):
+		return http.StatusBadRequest
+	case hasBehavior(err, errors.IsTemporary):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestNotesAPI returns the notes API generating the notes with generate,
+// and the keys it was called with.
+func newTestNotesAPI(generate func(tagName, previousTagName string) (app.ReleaseNote, error)) (*notesAPI, *[]notesKey) {
+	env := testEnv("")
+	env.APIToken = "secret"
+	api := newNotesAPI(env)
+	var calls []notesKey
+	api.generate = func(env envConfig, tagName, previousTagName string) (app.ReleaseNote, error) {
+		calls = append(calls, notesKey{projectID: env.ProjectID, from: previousTagName, to: tagName})
+		return generate(tagName, previousTagName)
+	}
+	return api, &calls
+}
+
+func getNotes(api *notesAPI, token, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, req)
+	return w
+}
+
+func testNote(tagName, previousTagName string) (app.ReleaseNote, error) {
+	return app.ReleaseNote{Tag: tagName, PreviousTag: previousTagName, Date: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}, nil
+}
+
+func TestNotesAPI_Token(t *testing.T) {
+	api, calls := newTestNotesAPI(testNote)
+
+	assert.Equal(t, http.StatusUnauthorized, getNotes(api, "", "/projects/42/notes").Code)
+	assert.Equal(t, http.StatusUnauthorized, getNotes(api, "wrong", "/projects/42/notes").Code)
+	assert.Empty(t, *calls)
+	assert.Equal(t, http.StatusOK, getNotes(api, "secret", "/projects/42/notes").Code)
+
+	// Without API_TOKEN, the API is not mounted and refuses every request.
+	env := testEnv("")
+	env.WebhookToken = "hook"
+	s, err := newReleaseServer(env, runOptions{})
+	assert.NoError(t, err)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/projects/42/notes", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	s.api.token = ""
+	assert.Equal(t, http.StatusUnauthorized, getNotes(s.api, "", "/projects/42/notes").Code)
+}
+
+func TestNotesAPI_Request(t *testing.T) {
+	api, calls := newTestNotesAPI(testNote)
+
+	tcs := []struct {
+		name   string
+		target string
+		code   int
+	}{
+		{"from without to", "/projects/42/notes?from=v1.0.0", http.StatusBadRequest},
+		{"invalid format", "/projects/42/notes?format=pdf", http.StatusBadRequest},
+		{"not notes", "/projects/42/tags", http.StatusNotFound},
+		{"missing project", "/projects//notes", http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, getNotes(api, "secret", tc.target).Code)
+		})
+	}
+	assert.Empty(t, *calls)
+
+	w := getNotes(api, "secret", "/projects/mygroup%2Fapi/notes?from=v1.0.0&to=v1.1.0&format=json")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, []notesKey{{projectID: "mygroup/api", from: "v1.0.0", to: "v1.1.0"}}, *calls)
+}
+
+func TestNotesAPI_Cache(t *testing.T) {
+	api, calls := newTestNotesAPI(testNote)
+
+	w := getNotes(api, "secret", "/projects/42/notes?to=v1.1.0")
+	assert.Equal(t, "MISS", w.Header().Get(cacheHeader))
+	assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+
+	// The cached note is served in another format.
+	w = getNotes(api, "secret", "/projects/42/notes?to=v1.1.0&format=html")
+	assert.Equal(t, "HIT", w.Header().Get(cacheHeader))
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = getNotes(api, "secret", "/projects/43/notes?to=v1.1.0")
+	assert.Equal(t, "MISS", w.Header().Get(cacheHeader))
+	assert.Len(t, *calls, 2)
+
+	api.cache[notesKey{projectID: "42", to: "v1.1.0"}] = cachedNote{expiresAt: time.Now().Add(-time.Second)}
+	w = getNotes(api, "secret", "/projects/42/notes?to=v1.1.0")
+	assert.Equal(t, "MISS", w.Header().Get(cacheHeader))
+	assert.Len(t, *calls, 3)
+}
+
+func TestNotesAPI_Errors(t *testing.T) {
+	tcs := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"not found", errors.WithNotFound(errors.New("Cannot find tag \"v9\"."), "tag_not_found"), http.StatusNotFound},
+		{"wrapped not found", errors.WithMessage(errors.WithNotFound(errors.New("Cannot find tag \"v9\"."), "tag_not_found"), "release v9"), http.StatusNotFound},
+		{"missing project", configError(errors.WithNotFound(errors.New("Project \"mygroup/missing\" not found."), "project_not_found")), http.StatusNotFound},
+		{"config", configError(errors.New("INCLUDE_COMPONENTS requires COMPONENT_RULES_FILE.")), http.StatusInternalServerError},
+		{"invalid", errors.WithMessage(errors.WithInvalid(errors.New("Invalid group by."), "invalid_group_by"), "content"), http.StatusBadRequest},
+		{"invalid range", errors.WithCode(errors.New("Tag \"v2\" is not older than tag \"v1\"."), app.InvalidTagRangeCode), http.StatusBadRequest},
+		{"temporary", errors.WithTemporary(errors.New("502 Bad Gateway"), "gitlab_unavailable"), http.StatusServiceUnavailable},
+		{"wrapped temporary", errors.WithStack(errors.WithTemporary(errors.New("502 Bad Gateway"), "gitlab_unavailable")), http.StatusServiceUnavailable},
+		{"other", errors.New("401 Unauthorized"), http.StatusBadGateway},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			api, _ := newTestNotesAPI(func(tagName, previousTagName string) (app.ReleaseNote, error) {
+				return app.ReleaseNote{}, tc.err
+			})
+
+			w := getNotes(api, "secret", "/projects/42/notes")
+			assert.Equal(t, tc.code, w.Code)
+			var resp statusResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, statusResponse{Status: "error", Message: tc.err.Error()}, resp)
+			assert.Empty(t, api.cache)
+		})
+	}
+}
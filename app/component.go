@@ -0,0 +1,165 @@
+package app
+
+import (
+	"bufio"
+	"gitLab-rls-note/pkg/errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PathPattern matches file paths with the CODEOWNERS syntax:
+//   - a pattern starting with "/" or containing a "/" in the middle is anchored
+//     to the repository root, otherwise it matches at any depth
+//   - a pattern ending with "/" only matches directories
+//   - "*" matches within a path segment, "**" matches across segments and "?"
+//     matches a single character
+//   - a pattern matching a directory matches every file under it
+type PathPattern struct {
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func CompilePathPattern(pattern string) (*PathPattern, error) {
+	p := strings.TrimSpace(pattern)
+	if p == "" {
+		return nil, errors.New("Path pattern must not be empty.")
+	}
+
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(strings.TrimSuffix(p, "/"), "/")
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.Trim(p, "/")
+
+	var expr strings.Builder
+	if anchored {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case p[i] == '*':
+			expr.WriteString("[^/]*")
+		case p[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+
+	if dirOnly {
+		expr.WriteString("/.*$")
+	} else {
+		expr.WriteString("(?:/.*)?$")
+	}
+
+	regex, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid path pattern %q", pattern)
+	}
+	return &PathPattern{pattern: pattern, regex: regex}, nil
+}
+
+func (p *PathPattern) Match(filePath string) bool {
+	return p.regex.MatchString(strings.TrimPrefix(filePath, "/"))
+}
+
+func (p *PathPattern) String() string {
+	return p.pattern
+}
+
+// MatchAnyPath reports whether any of the paths matches any of the patterns.
+func MatchAnyPath(patterns []*PathPattern, paths []string) bool {
+	for _, p := range patterns {
+		for _, filePath := range paths {
+			if p.Match(filePath) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type ComponentRule struct {
+	Pattern    *PathPattern
+	Components []string
+}
+
+// ComponentRules maps file paths to components, the last matching rule wins
+// like in a CODEOWNERS file.
+type ComponentRules []ComponentRule
+
+// LoadComponentRules reads the rules file, one rule per line:
+//
+//	# comment
+//	<path pattern> <component> [<component>...]
+func LoadComponentRules(file string) (ComponentRules, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	return ParseComponentRules(f)
+}
+
+func ParseComponentRules(r io.Reader) (ComponentRules, error) {
+	var rules ComponentRules
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, errors.Errorf("Invalid component rule at line %d: %s", lineNumber, line)
+		}
+
+		pattern, err := CompilePathPattern(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ComponentRule{Pattern: pattern, Components: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return rules, nil
+}
+
+// Components returns the components of a file path.
+func (rules ComponentRules) Components(filePath string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].Pattern.Match(filePath) {
+			return rules[i].Components
+		}
+	}
+	return nil
+}
+
+// ComponentsOf returns the distinct components of all the paths.
+func (rules ComponentRules) ComponentsOf(paths []string) []string {
+	var components []string
+	seen := make(map[string]bool)
+	for _, filePath := range paths {
+		for _, component := range rules.Components(filePath) {
+			if !seen[component] {
+				seen[component] = true
+				components = append(components, component)
+			}
+		}
+	}
+	return components
+}
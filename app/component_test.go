@@ -0,0 +1,53 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPattern_Match(t *testing.T) {
+	tcs := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.md", "README.md", true},
+		{"*.md", "docs/guide.md", true},
+		{"*.md", "docs/guide.go", false},
+		{"/services/billing/", "services/billing/main.go", true},
+		{"/services/billing/", "services/billing", false},
+		{"/services/billing/", "libs/services/billing/main.go", false},
+		{"services/billing/", "services/billing/api/handler.go", true},
+		{"billing/", "services/billing/main.go", true},
+		{"/web/**/*.ts", "web/app/components/button.ts", true},
+		{"/web/**/*.ts", "web/index.ts", true},
+		{"/web/**/*.ts", "web/index.js", false},
+		{"docs", "docs/guide.md", true},
+		{"/cmd/?ain.go", "cmd/main.go", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			p, err := CompilePathPattern(tc.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.match, p.Match(tc.path))
+		})
+	}
+}
+
+func TestComponentRules_LastMatchingRuleWins(t *testing.T) {
+	rules, err := ParseComponentRules(strings.NewReader(`
+# comment
+*                   core
+/services/billing/  billing
+/services/billing/docs/ docs billing
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"core"}, rules.Components("main.go"))
+	assert.Equal(t, []string{"billing"}, rules.Components("services/billing/main.go"))
+	assert.Equal(t, []string{"docs", "billing"}, rules.Components("services/billing/docs/README.md"))
+	assert.Equal(t, []string{"core", "billing"}, rules.ComponentsOf([]string{"go.mod", "services/billing/main.go"}))
+}
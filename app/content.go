@@ -3,6 +3,12 @@ package app
 import (
 	"fmt"
 	"gitLab-rls-note/pkg/errors"
+	"log/slog"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -18,124 +24,504 @@ var LABEL_CONFIG = []LabelConfig{
 
 const (
 	releaseNoteTimeFormat = "2006-01-02"
+
+	SortByDate     = "date"
+	SortByIID      = "iid"
+	SortByTitle    = "title"
+	SortByAuthor   = "author"
+	SortByPriority = "priority"
+
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+
+	defaultPriorityLabelPrefix = "priority::"
+
+	GroupByLabel     = "label"
+	GroupByPath      = "path"
+	GroupByScope     = "scope"
+	GroupByComponent = "component"
+	GroupByMilestone = "milestone"
+
+	defaultGroupLabelScope = "component"
+	defaultGroupOtherTitle = "Other"
+	scopedLabelSeparator   = "::"
 )
 
+var conventionalCommitRegex = regexp.MustCompile(`^\w+\(([^)]+)\)!?:`)
+
 type ContentService interface {
-	GenerateContent(mergeReqs []MergeRequest, issues []Issue, releaseDate time.Time) (string, error)
+	GenerateContent(mergeReqs []MergeRequest, issues []Issue, latestTag, previousTag Tag) (string, error)
+	// GenerateReleaseNote builds the release note model that GenerateContent
+	// renders as Markdown, for the publishers using other formats.
+	GenerateReleaseNote(mergeReqs []MergeRequest, issues []Issue, latestTag, previousTag Tag) (ReleaseNote, error)
 }
 type contentService struct {
 	labelConfigs []LabelConfig
-	labelBucket  map[string][]string
 	timeZone     *time.Location
+	config       ContentConfig
+	logger       *slog.Logger
 }
 
-func NewContentService(timeZone string) (ContentService, error) {
-	labelBucket := make(map[string][]string)
-	for _, item := range LABEL_CONFIG {
-		labelBucket[item.Name] = []string{}
-	}
+type ContentConfig struct {
+	TimeZone       string
+	IncludeSummary bool
+	// IncludeCommits reports the commits count in the summary, the commits
+	// are only retrieved when it is set.
+	IncludeCommits bool
+	// IssueClosedSeconds is the shift of the tag dates, the summary reports
+	// the dates of the tags without it.
+	IssueClosedSeconds int
+	// ProjectURL is the web URL of the project, used to build the compare link.
+	ProjectURL string
 
-	tz, err := time.LoadLocation(timeZone)
+	// SortBy and SortOrder sort the entries of every section, entries keep
+	// the API order when SortBy is empty.
+	SortBy    string
+	SortOrder string
+	// SectionSortBy and SectionSortOrder override the global sorting per
+	// section, keyed by label name.
+	SectionSortBy       map[string]string
+	SectionSortOrder    map[string]string
+	PriorityLabelPrefix string
+
+	// GroupBy subdivides every section by the scope of a scoped label, the
+	// directory of the changed files, the conventional-commit scope of the
+	// title, the components of the changed files or the milestone. Entries
+	// are not grouped when it is empty.
+	GroupBy         string
+	GroupLabelScope string
+	// GroupPathDepth is the number of directories used as group when grouping by path.
+	GroupPathDepth  int
+	GroupOtherTitle string
+	// GroupTitles maps group keys to their headings.
+	GroupTitles map[string]string
+	// ComponentRules maps the changed files to components.
+	ComponentRules ComponentRules
+
+	// Logger logs the sections of the entries. Default: slog.Default().
+	Logger *slog.Logger
+}
+
+func NewContentService(config ContentConfig) (ContentService, error) {
+	tz, err := time.LoadLocation(config.TimeZone)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	return &contentService{LABEL_CONFIG, labelBucket, tz}, nil
+
+	if err := validateSort(config.SortBy, config.SortOrder); err != nil {
+		return nil, err
+	}
+	for _, label := range LABEL_CONFIG {
+		if err := validateSort(config.SectionSortBy[label.Name], config.SectionSortOrder[label.Name]); err != nil {
+			return nil, err
+		}
+	}
+	if config.PriorityLabelPrefix == "" {
+		config.PriorityLabelPrefix = defaultPriorityLabelPrefix
+	}
+
+	switch config.GroupBy {
+	case "", GroupByLabel, GroupByPath, GroupByScope, GroupByMilestone:
+	case GroupByComponent:
+		if len(config.ComponentRules) == 0 {
+			return nil, errors.New("Grouping by component requires component rules.")
+		}
+	default:
+		return nil, errors.Errorf("Unsupported group by: %s", config.GroupBy)
+	}
+	if config.GroupLabelScope == "" {
+		config.GroupLabelScope = defaultGroupLabelScope
+	}
+	if config.GroupPathDepth < 1 {
+		config.GroupPathDepth = 1
+	}
+	if config.GroupOtherTitle == "" {
+		config.GroupOtherTitle = defaultGroupOtherTitle
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &contentService{LABEL_CONFIG, tz, config, logger}, nil
+}
+
+func validateSort(sortBy, sortOrder string) error {
+	switch sortBy {
+	case "", SortByDate, SortByIID, SortByTitle, SortByAuthor, SortByPriority:
+	default:
+		return errors.Errorf("Unsupported sort key: %s", sortBy)
+	}
+
+	switch sortOrder {
+	case "", SortOrderAsc, SortOrderDesc:
+	default:
+		return errors.Errorf("Unsupported sort order: %s", sortOrder)
+	}
+	return nil
 }
 
-func (s *contentService) GenerateContent(mergeReqs []MergeRequest, issues []Issue, releaseDate time.Time) (string, error) {
-	var dMrs []DecoratedMergeRequest
+func (s *contentService) GenerateContent(mergeReqs []MergeRequest, issues []Issue, latestTag, previousTag Tag) (string, error) {
+	note, err := s.GenerateReleaseNote(mergeReqs, issues, latestTag, previousTag)
+	if err != nil {
+		return "", err
+	}
+	return note.Markdown(), nil
+}
+
+func (s *contentService) GenerateReleaseNote(mergeReqs []MergeRequest, issues []Issue, latestTag, previousTag Tag) (ReleaseNote, error) {
+	var entries []Entry
 	for _, mr := range mergeReqs {
-		dMrs = append(dMrs, s.decorateMergeRequest(mr))
+		entries = append(entries, s.decorateMergeRequest(mr))
 	}
 
-	var dIssues []DecoratedIssue
 	for _, issue := range issues {
-		dIssues = append(dIssues, s.decorateIssue(issue))
+		entries = append(entries, s.decorateIssue(issue))
+	}
+
+	labelBucket := s.populateLabelBucket(entries)
+	for name, bucket := range labelBucket {
+		s.sortEntries(name, bucket)
 	}
 
-	s.populateLabelBucket(dMrs, dIssues)
+	note := ReleaseNote{
+		Tag:         latestTag.Name,
+		PreviousTag: previousTag.Name,
+		Date:        latestTag.Commit.CommittedDate.In(s.timeZone),
+		URL:         releaseURL(s.config.ProjectURL, latestTag.Name),
+		Sections:    s.generateSections(labelBucket),
+	}
+	if s.config.IncludeSummary {
+		note.Summary = s.generateSummary(mergeReqs, issues, latestTag, previousTag, labelBucket)
+	}
 
-	return s.generateReleaseNote(releaseDate)
+	sectionAttrs := make([]interface{}, 0, 2*len(note.Sections))
+	for _, section := range note.Sections {
+		sectionAttrs = append(sectionAttrs, section.Name, len(section.Entries))
+	}
+	s.logger.Info("Generated release note", "tag", note.Tag, "merge_requests", len(mergeReqs), "issues", len(issues),
+		slog.Group("sections", sectionAttrs...))
+	return note, nil
 }
 
-func (s *contentService) generateReleaseNote(releaseDate time.Time) (string, error) {
-	output := fmt.Sprintf("### Release note (%s)\n", releaseDate.In(s.timeZone).Format(releaseNoteTimeFormat))
+func (s *contentService) generateSections(labelBucket map[string][]Entry) []NoteSection {
+	var sections []NoteSection
 	for _, label := range s.labelConfigs {
-		if _, exists := s.labelBucket[label.Name]; exists {
-			bucket := s.labelBucket[label.Name]
-			isEmpty := len(bucket) > 0
-			if isEmpty {
-				output += fmt.Sprintf("#### %s\n", label.Title) + strings.Join(bucket, "\n") + "\n"
+		bucket := labelBucket[label.Name]
+		if len(bucket) == 0 {
+			continue
+		}
+
+		section := NoteSection{Name: label.Name, Title: label.Title, Entries: bucket}
+		if s.config.GroupBy != "" {
+			section.Groups = s.groupEntries(bucket)
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func joinMessages(entries []Entry) string {
+	messages := make([]string, len(entries))
+	for i, entry := range entries {
+		messages[i] = entry.Message
+	}
+	return strings.Join(messages, "\n") + "\n"
+}
+
+// groupEntries splits entries into groups ordered by title, keeping the "Other"
+// group last. An entry belonging to several groups is displayed in each of them.
+func (s *contentService) groupEntries(entries []Entry) []EntryGroup {
+	groupByKey := make(map[string]*EntryGroup)
+	var other EntryGroup
+	for _, entry := range entries {
+		keys := s.groupKeys(entry)
+		if len(keys) == 0 {
+			other.Entries = append(other.Entries, entry)
+			continue
+		}
+
+		for _, key := range keys {
+			group, exists := groupByKey[key]
+			if !exists {
+				title := key
+				if t, ok := s.config.GroupTitles[key]; ok {
+					title = t
+				}
+				group = &EntryGroup{Key: key, Title: title}
+				groupByKey[key] = group
 			}
+			group.Entries = append(group.Entries, entry)
 		}
 	}
-	return output, nil
+
+	groups := make([]EntryGroup, 0, len(groupByKey)+1)
+	for _, group := range groupByKey {
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].Title) < strings.ToLower(groups[j].Title)
+	})
+
+	if len(other.Entries) > 0 {
+		other.Title = s.config.GroupOtherTitle
+		groups = append(groups, other)
+	}
+	return groups
 }
 
-func (s *contentService) populateLabelBucket(mergeReqs []DecoratedMergeRequest, issues []DecoratedIssue) {
+func (s *contentService) groupKeys(entry Entry) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	addKey := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	switch s.config.GroupBy {
+	case GroupByLabel:
+		prefix := s.config.GroupLabelScope + scopedLabelSeparator
+		for _, label := range entry.Labels {
+			if strings.HasPrefix(label, prefix) {
+				addKey(strings.TrimPrefix(label, prefix))
+			}
+		}
+	case GroupByPath:
+		for _, p := range entry.Paths {
+			addKey(directoryAtDepth(p, s.config.GroupPathDepth))
+		}
+	case GroupByComponent:
+		for _, component := range entry.Components {
+			addKey(component)
+		}
+	case GroupByMilestone:
+		addKey(entry.Milestone)
+	case GroupByScope:
+		if match := conventionalCommitRegex.FindStringSubmatch(entry.Title); match != nil {
+			addKey(strings.TrimSpace(match[1]))
+		}
+	}
+	return keys
+}
+
+// directoryAtDepth returns the first depth directories of a file path, files
+// at the repository root have no directory.
+func directoryAtDepth(filePath string, depth int) string {
+	dirs := strings.Split(path.Dir(filePath), "/")
+	if dirs[0] == "." {
+		return ""
+	}
+	if len(dirs) > depth {
+		dirs = dirs[:depth]
+	}
+	return strings.Join(dirs, "/")
+}
+
+func (s *contentService) generateSummary(mergeReqs []MergeRequest, issues []Issue, latestTag, previousTag Tag, labelBucket map[string][]Entry) []string {
+	var shift time.Duration
+	if s.config.IssueClosedSeconds > 0 {
+		shift = time.Duration(s.config.IssueClosedSeconds) * time.Second
+	}
+	startDate := previousTag.Commit.CommittedDate.Add(-shift).In(s.timeZone)
+	endDate := latestTag.Commit.CommittedDate.Add(-shift).In(s.timeZone)
+	// A release within a day of the previous one is reported as 1 day.
+	span := endDate.Sub(startDate)
+	days := int(math.Round(span.Hours() / 24))
+	if days == 0 && span > 0 {
+		days = 1
+	}
+
+	summary := []string{fmt.Sprintf("Release range: %s to %s (%d days since previous release)",
+		startDate.Format(releaseNoteTimeFormat), endDate.Format(releaseNoteTimeFormat), days)}
+
+	commits := 0
+	contributors := make(map[string]struct{})
 	for _, mr := range mergeReqs {
+		commits += len(mr.Commits)
+		if mr.Author.Username != "" {
+			contributors[mr.Author.Username] = struct{}{}
+		}
+	}
+	counts := fmt.Sprintf("Merged requests: %d, closed issues: %d", len(mergeReqs), len(issues))
+	if s.config.IncludeCommits {
+		counts += fmt.Sprintf(", commits: %d", commits)
+	}
+	summary = append(summary, counts+fmt.Sprintf(", contributors: %d", len(contributors)))
+
+	var sectionCounts []string
+	for _, label := range s.labelConfigs {
+		if count := len(labelBucket[label.Name]); count > 0 {
+			sectionCounts = append(sectionCounts, fmt.Sprintf("%s: %d", label.Title, count))
+		}
+	}
+	if len(sectionCounts) > 0 {
+		summary = append(summary, strings.Join(sectionCounts, ", "))
+	}
+
+	if s.config.ProjectURL != "" && previousTag.Name != "" && latestTag.Name != "" {
+		compare := fmt.Sprintf("%s...%s", previousTag.Name, latestTag.Name)
+		summary = append(summary, fmt.Sprintf("Compare: [%s](%s/-/compare/%s)", compare, s.config.ProjectURL, compare))
+	}
+	return summary
+}
+
+func (s *contentService) populateLabelBucket(entries []Entry) map[string][]Entry {
+	labelBucket := make(map[string][]Entry)
+	for _, item := range s.labelConfigs {
+		labelBucket[item.Name] = []Entry{}
+	}
+
+	for _, entry := range entries {
 		added := false
-		for _, label := range mr.Labels {
-			if _, exists := s.labelBucket[label]; exists {
-				s.labelBucket[label] = append(s.labelBucket[label], mr.Message)
+		for _, label := range entry.Labels {
+			if _, exists := labelBucket[label]; exists {
+				labelBucket[label] = append(labelBucket[label], entry)
 				added = true
 			}
 		}
 
 		if !added {
-			s.labelBucket[mr.DefaultLabel] = append(s.labelBucket[mr.DefaultLabel], mr.Message)
+			s.logger.Debug("Entry without section label", "iid", entry.IID, "section", entry.DefaultLabel)
+			labelBucket[entry.DefaultLabel] = append(labelBucket[entry.DefaultLabel], entry)
 		}
 	}
+	return labelBucket
+}
 
-	for _, issue := range issues {
-		added := false
-		for _, label := range issue.Labels {
-			if _, exists := s.labelBucket[label]; exists {
-				s.labelBucket[label] = append(s.labelBucket[label], issue.Message)
-				added = true
+func (s *contentService) sortEntries(labelName string, entries []Entry) {
+	sortBy, sortOrder := s.config.SortBy, s.config.SortOrder
+	if by := s.config.SectionSortBy[labelName]; by != "" {
+		sortBy = by
+	}
+	if order := s.config.SectionSortOrder[labelName]; order != "" {
+		sortOrder = order
+	}
+	if sortBy == "" {
+		return
+	}
+
+	desc := sortOrder == SortOrderDesc
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch sortBy {
+		case SortByDate:
+			if desc {
+				return a.Date.After(b.Date)
+			}
+			return a.Date.Before(b.Date)
+		case SortByIID:
+			if desc {
+				return a.IID > b.IID
 			}
+			return a.IID < b.IID
+		case SortByTitle:
+			return compareStrings(strings.ToLower(a.Title), strings.ToLower(b.Title), desc)
+		case SortByAuthor:
+			return compareStrings(strings.ToLower(a.Author), strings.ToLower(b.Author), desc)
+		case SortByPriority:
+			pa, okA := s.priority(a)
+			pb, okB := s.priority(b)
+			// Entries without a priority label always go last.
+			if !okA || !okB {
+				return okA && !okB
+			}
+			na, errA := strconv.Atoi(pa)
+			nb, errB := strconv.Atoi(pb)
+			if errA == nil && errB == nil {
+				if desc {
+					return na > nb
+				}
+				return na < nb
+			}
+			return compareStrings(pa, pb, desc)
 		}
+		return false
+	})
+}
 
-		if !added {
-			s.labelBucket[issue.DefaultLabel] = append(s.labelBucket[issue.DefaultLabel], issue.Message)
+func (s *contentService) priority(entry Entry) (string, bool) {
+	for _, label := range entry.Labels {
+		if strings.HasPrefix(label, s.config.PriorityLabelPrefix) {
+			return strings.TrimPrefix(label, s.config.PriorityLabelPrefix), true
 		}
 	}
+	return "", false
+}
+
+func compareStrings(a, b string, desc bool) bool {
+	if desc {
+		return a > b
+	}
+	return a < b
 }
 
-func (s *contentService) decorateMergeRequest(mr MergeRequest) DecoratedMergeRequest {
+func (s *contentService) decorateMergeRequest(mr MergeRequest) Entry {
+	paths := mr.ChangedPaths()
 	msg := fmt.Sprintf("- %s [#%d](%s) ([%s](%s))", mr.Title, mr.IID, mr.WebURL, mr.Author.Username, mr.Author.WebURL)
 	for _, commit := range mr.Commits {
 		msg += fmt.Sprintf("\n  - %s [#%s](%s) (%s)", commit.Title, commit.ShortID, commit.WebURL, commit.AuthorEmail)
 	}
-	return DecoratedMergeRequest{
+	return Entry{
 		Message:      msg,
 		Labels:       mr.Labels,
 		DefaultLabel: "mergeRequests",
+		IID:          mr.IID,
+		Title:        mr.Title,
+		URL:          mr.WebURL,
+		Author:       mr.Author.Username,
+		Date:         mr.MergedAt,
+		Paths:        paths,
+		Components:   s.config.ComponentRules.ComponentsOf(paths),
+		Milestone:    milestoneTitle(mr.Milestone),
 	}
 }
 
-func (s *contentService) decorateIssue(issue Issue) DecoratedIssue {
+func (s *contentService) decorateIssue(issue Issue) Entry {
 	msg := fmt.Sprintf("- %s [#%d](%s)", issue.Title, issue.IID, issue.WebURL)
-	return DecoratedIssue{
+	return Entry{
 		Message:      msg,
 		Labels:       issue.Labels,
 		DefaultLabel: "issues",
+		IID:          issue.IID,
+		Title:        issue.Title,
+		URL:          issue.WebURL,
+		Author:       issue.Author.Username,
+		Date:         issue.ClosedAt,
+		Milestone:    milestoneTitle(issue.Milestone),
 	}
 }
 
+func milestoneTitle(milestone *Milestone) string {
+	if milestone == nil {
+		return ""
+	}
+	return milestone.Title
+}
+
 type LabelConfig struct {
 	Name  string
 	Title string
 }
 
-type DecoratedMergeRequest struct {
+type Entry struct {
 	Message      string
 	Labels       []string
 	DefaultLabel string
+	IID          int
+	Title        string
+	URL          string
+	Author       string
+	// Date is the merge date of a merge request or the close date of an issue.
+	Date time.Time
+	// Paths are the files changed by a merge request.
+	Paths      []string
+	Components []string
+	Milestone  string
 }
 
-type DecoratedIssue struct {
-	Message      string
-	Labels       []string
-	DefaultLabel string
+type EntryGroup struct {
+	Key     string
+	Title   string
+	Entries []Entry
 }
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestContentService(t *testing.T, config ContentConfig) ContentService {
+	if config.TimeZone == "" {
+		config.TimeZone = "UTC"
+	}
+	svc, err := NewContentService(config)
+	assert.NoError(t, err)
+	return svc
+}
+
+func TestGenerateReleaseNote_Summary(t *testing.T) {
+	previousTag := Tag{Name: "v1.0", Commit: Commit{CommittedDate: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}}
+	latestTag := Tag{Name: "v1.1", Commit: Commit{CommittedDate: time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)}}
+	var mr MergeRequest
+	mr.IID, mr.Labels, mr.Author.Username = 1, []string{"bug"}, "alice"
+	mr.Commits = []MRCommit{{ShortID: "a1"}, {ShortID: "a2"}}
+	mrs := []MergeRequest{mr}
+	issues := []Issue{{IID: 7}}
+
+	tcs := []struct {
+		name    string
+		config  ContentConfig
+		summary []string
+	}{
+		{
+			name:   "without commits",
+			config: ContentConfig{IncludeSummary: true},
+			summary: []string{
+				"Release range: 2024-03-01 to 2024-03-15 (14 days since previous release)",
+				"Merged requests: 1, closed issues: 1, contributors: 1",
+				"Fixed bugs: 1, Closed issues: 1",
+			},
+		},
+		{
+			name:   "with commits and compare link",
+			config: ContentConfig{IncludeSummary: true, IncludeCommits: true, ProjectURL: "https://gitlab.example.com/group/project"},
+			summary: []string{
+				"Release range: 2024-03-01 to 2024-03-15 (14 days since previous release)",
+				"Merged requests: 1, closed issues: 1, commits: 2, contributors: 1",
+				"Fixed bugs: 1, Closed issues: 1",
+				"Compare: [v1.0...v1.1](https://gitlab.example.com/group/project/-/compare/v1.0...v1.1)",
+			},
+		},
+		{
+			name:    "disabled",
+			config:  ContentConfig{IncludeCommits: true},
+			summary: nil,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			note, err := newTestContentService(t, tc.config).GenerateReleaseNote(mrs, issues, latestTag, previousTag)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.summary, note.Summary)
+		})
+	}
+}
+
+func TestGenerateReleaseNote_SummaryRangeIgnoresIssueClosedSeconds(t *testing.T) {
+	shift := 2 * time.Hour
+	previousTag := Tag{Name: "v1.0", Commit: Commit{CommittedDate: time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC).Add(shift)}}
+	latestTag := Tag{Name: "v1.1", Commit: Commit{CommittedDate: time.Date(2024, 3, 8, 23, 0, 0, 0, time.UTC).Add(shift)}}
+
+	svc := newTestContentService(t, ContentConfig{IncludeSummary: true, IssueClosedSeconds: int(shift.Seconds())})
+	note, err := svc.GenerateReleaseNote(nil, nil, latestTag, previousTag)
+	assert.NoError(t, err)
+	assert.Equal(t, "Release range: 2024-03-01 to 2024-03-08 (7 days since previous release)", note.Summary[0])
+}
+
+func TestGenerateReleaseNote_SummaryDays(t *testing.T) {
+	previousDate := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		span time.Duration
+		days string
+	}{
+		{0, "0 days"},
+		{5 * time.Hour, "1 days"},
+		{23 * time.Hour, "1 days"},
+		{36 * time.Hour, "2 days"},
+		{7*24*time.Hour - time.Hour, "7 days"},
+	}
+
+	svc := newTestContentService(t, ContentConfig{IncludeSummary: true})
+	for _, tc := range tcs {
+		t.Run(tc.span.String(), func(t *testing.T) {
+			previousTag := Tag{Name: "v1.0", Commit: Commit{CommittedDate: previousDate}}
+			latestTag := Tag{Name: "v1.1", Commit: Commit{CommittedDate: previousDate.Add(tc.span)}}
+			note, err := svc.GenerateReleaseNote(nil, nil, latestTag, previousTag)
+			assert.NoError(t, err)
+			assert.Contains(t, note.Summary[0], "("+tc.days+" since previous release)")
+		})
+	}
+}
+
+func iids(entries []Entry) []int {
+	var result []int
+	for _, entry := range entries {
+		result = append(result, entry.IID)
+	}
+	return result
+}
+
+func TestGenerateReleaseNote_Sort(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC) }
+	newMR := func(iid int, title, author string, mergedAt time.Time, labels ...string) MergeRequest {
+		var mr MergeRequest
+		mr.IID, mr.Title, mr.Author.Username, mr.MergedAt = iid, title, author, mergedAt
+		mr.Labels = append([]string{"bug"}, labels...)
+		return mr
+	}
+	mrs := []MergeRequest{
+		newMR(3, "b fix", "carol", day(2), "priority::2"),
+		newMR(1, "C fix", "alice", day(3)),
+		newMR(2, "a fix", "Bob", day(1), "priority::10"),
+		newMR(4, "d fix", "dave", day(4), "priority::1"),
+	}
+
+	tcs := []struct {
+		name   string
+		config ContentConfig
+		iids   []int
+	}{
+		{"api order", ContentConfig{}, []int{3, 1, 2, 4}},
+		{"date", ContentConfig{SortBy: SortByDate}, []int{2, 3, 1, 4}},
+		{"iid desc", ContentConfig{SortBy: SortByIID, SortOrder: SortOrderDesc}, []int{4, 3, 2, 1}},
+		{"title ignores case", ContentConfig{SortBy: SortByTitle}, []int{2, 3, 1, 4}},
+		{"author ignores case", ContentConfig{SortBy: SortByAuthor}, []int{1, 2, 3, 4}},
+		{"numeric priority, missing last", ContentConfig{SortBy: SortByPriority}, []int{4, 3, 2, 1}},
+		{"priority desc, missing last", ContentConfig{SortBy: SortByPriority, SortOrder: SortOrderDesc}, []int{2, 3, 4, 1}},
+		{"section override", ContentConfig{
+			SortBy:           SortByDate,
+			SectionSortBy:    map[string]string{"bug": SortByIID},
+			SectionSortOrder: map[string]string{"bug": SortOrderDesc},
+		}, []int{4, 3, 2, 1}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			note, err := newTestContentService(t, tc.config).GenerateReleaseNote(mrs, nil, Tag{}, Tag{})
+			assert.NoError(t, err)
+			assert.Equal(t, "bug", note.Sections[0].Name)
+			assert.Equal(t, tc.iids, iids(note.Sections[0].Entries))
+		})
+	}
+}
+
+func TestGenerateReleaseNote_PriorityLabelPrefix(t *testing.T) {
+	var low, high MergeRequest
+	low.IID, low.Labels = 1, []string{"bug", "P-low"}
+	high.IID, high.Labels = 2, []string{"bug", "P-high"}
+
+	svc := newTestContentService(t, ContentConfig{SortBy: SortByPriority, PriorityLabelPrefix: "P-"})
+	note, err := svc.GenerateReleaseNote([]MergeRequest{low, high}, nil, Tag{}, Tag{})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, iids(note.Sections[0].Entries))
+}
+
+func TestNewContentService_InvalidSort(t *testing.T) {
+	tcs := []ContentConfig{
+		{SortBy: "size"},
+		{SortBy: SortByDate, SortOrder: "up"},
+		{SectionSortBy: map[string]string{"bug": "size"}},
+		{SectionSortOrder: map[string]string{"feature": "down"}},
+	}
+
+	for _, tc := range tcs {
+		tc.TimeZone = "UTC"
+		_, err := NewContentService(tc)
+		assert.Error(t, err)
+	}
+}
+
+func groupIIDs(groups []EntryGroup) map[string][]int {
+	result := make(map[string][]int)
+	for _, group := range groups {
+		result[group.Title] = iids(group.Entries)
+	}
+	return result
+}
+
+func TestGenerateReleaseNote_Group(t *testing.T) {
+	newMR := func(iid int, title string, labels []string, paths ...string) MergeRequest {
+		var mr MergeRequest
+		mr.IID, mr.Title = iid, title
+		mr.Labels = append([]string{"feature"}, labels...)
+		for _, p := range paths {
+			mr.Changes = append(mr.Changes, MRChange{OldPath: p, NewPath: p})
+		}
+		return mr
+	}
+	mrs := []MergeRequest{
+		newMR(1, "feat(api): add endpoint", []string{"component::api"}, "services/api/handler.go", "services/api/routes.go"),
+		newMR(2, "feat(web)!: new layout", []string{"component::web", "component::api"}, "web/index.ts", "services/api/handler.go"),
+		newMR(3, "Update readme", nil, "README.md"),
+	}
+	mrs[0].Milestone = &Milestone{Title: "2024.06"}
+	mrs[1].Milestone = &Milestone{Title: "2024.05"}
+
+	tcs := []struct {
+		name   string
+		config ContentConfig
+		groups map[string][]int
+		titles []string
+	}{
+		{
+			name:   "label",
+			config: ContentConfig{GroupBy: GroupByLabel, GroupTitles: map[string]string{"web": "Web app"}},
+			groups: map[string][]int{"api": {1, 2}, "Web app": {2}, "Other": {3}},
+			titles: []string{"api", "Web app", "Other"},
+		},
+		{
+			name:   "path depth",
+			config: ContentConfig{GroupBy: GroupByPath, GroupPathDepth: 2},
+			groups: map[string][]int{"services/api": {1, 2}, "web": {2}, "Other": {3}},
+			titles: []string{"services/api", "web", "Other"},
+		},
+		{
+			name:   "path",
+			config: ContentConfig{GroupBy: GroupByPath, GroupOtherTitle: "Misc"},
+			groups: map[string][]int{"services": {1, 2}, "web": {2}, "Misc": {3}},
+			titles: []string{"services", "web", "Misc"},
+		},
+		{
+			name:   "scope",
+			config: ContentConfig{GroupBy: GroupByScope},
+			groups: map[string][]int{"api": {1}, "web": {2}, "Other": {3}},
+			titles: []string{"api", "web", "Other"},
+		},
+		{
+			name:   "milestone",
+			config: ContentConfig{GroupBy: GroupByMilestone},
+			groups: map[string][]int{"2024.06": {1}, "2024.05": {2}, "Other": {3}},
+			titles: []string{"2024.05", "2024.06", "Other"},
+		},
+		{
+			name:   "label scope",
+			config: ContentConfig{GroupBy: GroupByLabel, GroupLabelScope: "team"},
+			groups: map[string][]int{"Other": {1, 2, 3}},
+			titles: []string{"Other"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			note, err := newTestContentService(t, tc.config).GenerateReleaseNote(mrs, nil, Tag{}, Tag{})
+			assert.NoError(t, err)
+			groups := note.Sections[0].Groups
+			assert.Equal(t, tc.groups, groupIIDs(groups))
+
+			var titles []string
+			for _, group := range groups {
+				titles = append(titles, group.Title)
+			}
+			assert.Equal(t, tc.titles, titles)
+		})
+	}
+}
+
+func TestGenerateReleaseNote_GroupMarkdown(t *testing.T) {
+	var api, other MergeRequest
+	api.IID, api.Title, api.WebURL, api.Labels = 1, "Add endpoint", "https://gitlab.example.com/mr/1", []string{"bug", "component::api"}
+	other.IID, other.Title, other.WebURL, other.Labels = 2, "Fix typo", "https://gitlab.example.com/mr/2", []string{"bug"}
+
+	svc := newTestContentService(t, ContentConfig{GroupBy: GroupByLabel})
+	content, err := svc.GenerateContent([]MergeRequest{other, api}, nil, Tag{}, Tag{})
+	assert.NoError(t, err)
+	assert.Equal(t, "### Release note (0001-01-01)\n"+
+		"#### Fixed bugs\n"+
+		"##### api\n"+
+		"- Add endpoint [#1](https://gitlab.example.com/mr/1) ([]())\n"+
+		"##### Other\n"+
+		"- Fix typo [#2](https://gitlab.example.com/mr/2) ([]())\n", content)
+}
+
+func TestNewContentService_InvalidGroupBy(t *testing.T) {
+	_, err := NewContentService(ContentConfig{TimeZone: "UTC", GroupBy: "size"})
+	assert.Error(t, err)
+
+	_, err = NewContentService(ContentConfig{TimeZone: "UTC", GroupBy: GroupByComponent})
+	assert.EqualError(t, err, "Grouping by component requires component rules.")
+}
+
+func TestDirectoryAtDepth(t *testing.T) {
+	assert.Equal(t, "", directoryAtDepth("go.mod", 1))
+	assert.Equal(t, "services", directoryAtDepth("services/api/handler.go", 1))
+	assert.Equal(t, "services/api", directoryAtDepth("services/api/handler.go", 3))
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"gitLab-rls-note/pkg/errors"
+	"strings"
+)
+
+const (
+	DescriptionStartMarker = "<!-- rlsnote:start -->"
+	DescriptionEndMarker   = "<!-- rlsnote:end -->"
+
+	// Policies applied when updating a release description without markers.
+	MarkerFallbackOverwrite = "overwrite"
+	MarkerFallbackAppend    = "append"
+	MarkerFallbackPrepend   = "prepend"
+	MarkerFallbackRefuse    = "refuse"
+
+	missingMarkersCode = "release_missing_markers"
+)
+
+func ValidateMarkerFallback(fallback string) error {
+	switch fallback {
+	case "", MarkerFallbackOverwrite, MarkerFallbackAppend, MarkerFallbackPrepend, MarkerFallbackRefuse:
+		return nil
+	default:
+		return errors.Errorf("Unsupported marker fallback: %s", fallback)
+	}
+}
+
+// wrapGeneratedContent surrounds the generated content with the markers.
+func wrapGeneratedContent(content string) string {
+	return DescriptionStartMarker + "\n" + strings.TrimRight(content, "\n") + "\n" + DescriptionEndMarker
+}
+
+// mergeDescription replaces the generated region of an existing description,
+// leaving the text above and below the markers intact. The fallback policy
+// applies when the description has no markers.
+func mergeDescription(existing, content, fallback string) (string, error) {
+	generated := wrapGeneratedContent(content)
+
+	// The start marker is the one closest to the end marker, a lone marker
+	// left above by an earlier edit is kept as text.
+	end := strings.LastIndex(existing, DescriptionEndMarker)
+	start := -1
+	if end >= 0 {
+		start = strings.LastIndex(existing[:end], DescriptionStartMarker)
+	}
+	if start >= 0 {
+		return existing[:start] + generated + existing[end+len(DescriptionEndMarker):], nil
+	}
+
+	if strings.TrimSpace(existing) == "" {
+		return generated, nil
+	}
+
+	switch fallback {
+	case MarkerFallbackAppend:
+		return strings.TrimRight(existing, "\n") + "\n\n" + generated, nil
+	case MarkerFallbackPrepend:
+		return generated + "\n\n" + strings.TrimLeft(existing, "\n"), nil
+	case MarkerFallbackRefuse:
+		return "", errors.WithCode(errors.New("The release description has no generated content markers, refusing to update it."), missingMarkersCode)
+	default:
+		return generated, nil
+	}
+}
+
+// NormalizeDescription ignores the line endings and trailing spaces that
+// GitLab may change when storing a description.
+func NormalizeDescription(description string) string {
+	lines := strings.Split(strings.ReplaceAll(description, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func isSameDescription(a, b string) bool {
+	return NormalizeDescription(a) == NormalizeDescription(b)
+}
+
+// isSameMilestones reports whether the release keeps its milestones, an empty
+// list leaves the existing milestones untouched.
+func isSameMilestones(existing, milestones []string) bool {
+	if len(milestones) == 0 {
+		return true
+	}
+	if len(existing) != len(milestones) {
+		return false
+	}
+
+	seen := make(map[string]bool)
+	for _, milestone := range existing {
+		seen[milestone] = true
+	}
+	for _, milestone := range milestones {
+		if !seen[milestone] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"testing"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDescription(t *testing.T) {
+	generated := wrapGeneratedContent("new note")
+	lone := DescriptionStartMarker + "\nedited by hand"
+
+	tcs := []struct {
+		name        string
+		existing    string
+		fallback    string
+		description string
+	}{
+		{"empty", " \n", MarkerFallbackRefuse, generated},
+		{"markers", "Intro\n" + wrapGeneratedContent("old note") + "\nOutro", MarkerFallbackRefuse, "Intro\n" + generated + "\nOutro"},
+		{"overwrite", "Written by hand", "", generated},
+		{"append", "Written by hand\n\n", MarkerFallbackAppend, "Written by hand\n\n" + generated},
+		{"prepend", "\nWritten by hand", MarkerFallbackPrepend, generated + "\n\nWritten by hand"},
+		{"only start marker", lone, MarkerFallbackAppend, lone + "\n\n" + generated},
+		{"only end marker", "Written by hand\n" + DescriptionEndMarker, MarkerFallbackPrepend, generated + "\n\nWritten by hand\n" + DescriptionEndMarker},
+		{"markers in reverse order", DescriptionEndMarker + " " + DescriptionStartMarker, "", generated},
+		{"lone start marker above the markers", lone + "\n\n" + wrapGeneratedContent("old note"), MarkerFallbackRefuse, lone + "\n\n" + generated},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			description, err := mergeDescription(tc.existing, "new note\n", tc.fallback)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.description, description)
+		})
+	}
+}
+
+func TestMergeDescription_Refuse(t *testing.T) {
+	_, err := mergeDescription("Written by hand\n"+DescriptionStartMarker, "new note", MarkerFallbackRefuse)
+	assert.EqualError(t, err, "The release description has no generated content markers, refusing to update it.")
+	assert.Equal(t, missingMarkersCode, errors.ErrorCode(err))
+}
+
+func TestNormalizeDescription(t *testing.T) {
+	description := wrapGeneratedContent("- Fix login\n- New page")
+	stored := "\r\n" + DescriptionStartMarker + "  \r\n- Fix login\t\r\n- New page\r\n" + DescriptionEndMarker + "\r\n"
+
+	assert.Equal(t, description, NormalizeDescription(stored))
+	assert.True(t, isSameDescription(description, stored))
+	assert.False(t, isSameDescription(description, wrapGeneratedContent("- Fix login")))
+}
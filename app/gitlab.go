@@ -2,8 +2,10 @@ package app
 
 import (
 	"gitLab-rls-note/pkg/errors"
+	"log/slog"
 	"net/url"
 	"regexp"
+	"strings"
 	"time"
 )
 
@@ -15,17 +17,57 @@ const (
 	GitLabDefaultPerPage = 20
 
 	lookingSecondTagLimit = 100
+
+	packageRegexGroup          = "package"
+	packagePathTemplateKey     = "{package}"
+	defaultPackagePathTemplate = "{package}/"
+
+	// TagNotFoundCode is the code of a missing tag.
+	TagNotFoundCode = "tag_not_found"
+	// InvalidTagRangeCode is the code of a previous tag not older than the tag.
+	InvalidTagRangeCode = "invalid_tag_range"
 )
 
 type GitLabService interface {
 	RetrieveTwoLatestTags() ([]Tag, error)
-	RetrieveChangelogsByStartAndEndDate(startDate, endDate time.Time) ([]MergeRequest, []Issue, error)
-	Publish(tag Tag, content string) error
+	RetrieveChangelogs(latestTag, previousTag Tag) ([]MergeRequest, []Issue, error)
+	RetrieveTagAndPreviousTag(tagName string) ([]Tag, error)
+	RetrieveTagRange(tagName, previousTagName string) ([]Tag, error)
+	IsTagInTargetBranch(tagName string) (bool, error)
+	RetrieveMergeRequestDetails(mrs []MergeRequest, tag Tag) ([]MergeRequest, error)
+	RetrieveRepo() (Repo, error)
+	ReleaseMilestones(mergeReqs []MergeRequest, issues []Issue) []string
+	PreviewDescription(tag Tag, content string) (string, string, error)
+	Publish(tag Tag, content string, milestones []string) (PublishResult, error)
+	Rollback(entry HistoryEntry) (PublishResult, error)
+}
+
+type PublishStatus string
+
+const (
+	PublishCreated    PublishStatus = "created"
+	PublishUpdated    PublishStatus = "updated"
+	PublishUnchanged  PublishStatus = "unchanged"
+	PublishDeleted    PublishStatus = "deleted"
+	PublishRolledBack PublishStatus = "rolled back"
+	// PublishSent is the status of the publishers sending the note, eg: chats.
+	PublishSent    PublishStatus = "sent"
+	PublishSkipped PublishStatus = "skipped"
+	PublishFailed  PublishStatus = "failed"
+)
+
+type PublishResult struct {
+	Status              PublishStatus
+	PreviousDescription string
+	Description         string
+	PreviousMilestones  []string
+	Milestones          []string
 }
 
 type gitLabService struct {
 	client GitLabClient
 	config Config
+	logger *slog.Logger
 }
 
 type Config struct {
@@ -33,23 +75,257 @@ type Config struct {
 	TargetTagRegex     string
 	IssueClosedSeconds int
 	IncludeCommits     bool
+	IncludeChanges     bool
+	// IncludePaths and IncludeComponents only keep the merge requests that
+	// change a file matching one of the path patterns or belonging to one of
+	// the components. They imply IncludeChanges. The issues are not
+	// filtered, they change no file.
+	IncludePaths      []string
+	IncludeComponents []string
+	ComponentRules    ComponentRules
+
+	// Monorepo identifies the package of a tag by the named group "package"
+	// or the first capture group of TargetTagRegex. The previous tag is
+	// searched among the tags of the same package and the merge requests are
+	// filtered to the ones changing the package path. It implies IncludeChanges.
+	Monorepo bool
+	// TargetPackage selects the package to release, it defaults to the
+	// package of the latest tag.
+	TargetPackage string
+	// PackagePaths maps packages to path patterns, the packages without
+	// one use PackagePathTemplate.
+	PackagePaths        map[string]string
+	PackagePathTemplate string
+
+	// AssetLinks are added to new releases and reconciled on existing ones.
+	AssetLinks []ReleaseLink
+
+	// Milestones are associated with the release, they are inferred from the
+	// merge requests and issues when empty and InferMilestones is set.
+	Milestones      []string
+	InferMilestones bool
+
+	// MarkerFallback is the policy applied when an existing release
+	// description has no generated content markers: overwrite, append,
+	// prepend or refuse. Default: overwrite.
+	MarkerFallback string
+
+	// Logger logs the chosen tags, the retrieved pages and the filtered
+	// merge requests. Default: slog.Default().
+	Logger *slog.Logger
 }
 
 func NewGitLabService(client GitLabClient, config Config) GitLabService {
-	return &gitLabService{client: client, config: config}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	// The merge requests are filtered by their changes.
+	if config.Monorepo || len(config.IncludePaths) > 0 || len(config.IncludeComponents) > 0 {
+		config.IncludeChanges = true
+	}
+	return &gitLabService{client: client, config: config, logger: logger}
 }
 
-func (s *gitLabService) Publish(tag Tag, content string) error {
-	body := Release{tag.Name, content}
+// ReleaseMilestones returns the configured milestones, or the distinct
+// milestones of the merge requests and issues when inferring them.
+func (s *gitLabService) ReleaseMilestones(mergeReqs []MergeRequest, issues []Issue) []string {
+	if len(s.config.Milestones) > 0 || !s.config.InferMilestones {
+		return s.config.Milestones
+	}
+
+	var milestones []string
+	seen := make(map[string]bool)
+	addMilestone := func(milestone *Milestone) {
+		if milestone != nil && milestone.Title != "" && !seen[milestone.Title] {
+			seen[milestone.Title] = true
+			milestones = append(milestones, milestone.Title)
+		}
+	}
+	for _, mr := range mergeReqs {
+		addMilestone(mr.Milestone)
+	}
+	for _, issue := range issues {
+		addMilestone(issue.Milestone)
+	}
+	return milestones
+}
+
+// PreviewDescription returns the current release description of the tag and
+// the description Publish would write, without publishing.
+func (s *gitLabService) PreviewDescription(tag Tag, content string) (string, string, error) {
+	if tag.Release.Name == "" {
+		return "", wrapGeneratedContent(content), nil
+	}
+
+	existing, err := s.client.RetrieveRelease(tag.Name)
+	if err != nil {
+		return "", "", err
+	}
+
+	description, err := mergeDescription(existing.Description, content, s.config.MarkerFallback)
+	if err != nil {
+		return "", "", errors.WithMessagef(err, "release %s", tag.Name)
+	}
+	return existing.Description, description, nil
+}
+
+// Publish creates the release of the tag, or updates the generated region of
+// the existing release description. The update is skipped when neither the
+// normalized description nor the milestones changed.
+func (s *gitLabService) Publish(tag Tag, content string, milestones []string) (PublishResult, error) {
+	body := Release{Name: tag.Name, Description: wrapGeneratedContent(content)}
+	if len(milestones) > 0 {
+		body.Milestones = milestones
+	}
 	if tag.Release.Name != "" {
-		err := s.client.UpdateTagRelease(body)
-		return err
+		existing, err := s.client.RetrieveRelease(tag.Name)
+		if err != nil {
+			return PublishResult{}, err
+		}
+
+		body.Description, err = mergeDescription(existing.Description, content, s.config.MarkerFallback)
+		if err != nil {
+			return PublishResult{}, errors.WithMessagef(err, "release %s", tag.Name)
+		}
+
+		result := PublishResult{Status: PublishUnchanged, PreviousDescription: existing.Description, Description: body.Description}
+		if len(milestones) > 0 {
+			result.PreviousMilestones, result.Milestones = existing.Milestones, milestones
+		}
+		if !isSameDescription(existing.Description, body.Description) || !isSameMilestones(existing.Milestones, milestones) {
+			result.Status = PublishUpdated
+			err = s.client.UpdateTagRelease(body)
+			if err != nil {
+				return PublishResult{}, err
+			}
+		}
+		return result, s.reconcileReleaseLinks(tag.Name)
+	}
+
+	if len(s.config.AssetLinks) > 0 {
+		body.Assets = &ReleaseAssets{Links: s.config.AssetLinks}
 	}
 	err := s.client.CreateTagRelease(body)
-	return err
+	if err != nil {
+		return PublishResult{}, err
+	}
+	return PublishResult{Status: PublishCreated, Description: body.Description, Milestones: body.Milestones}, nil
+}
+
+// Rollback restores the release description and milestones saved by a
+// history entry, a release created by the entry is deleted and a deleted one
+// is created again.
+func (s *gitLabService) Rollback(entry HistoryEntry) (PublishResult, error) {
+	if entry.Status == PublishDeleted {
+		err := s.client.CreateTagRelease(Release{Name: entry.Tag, Description: entry.PreviousDescription, Milestones: entry.PreviousMilestones})
+		if err != nil {
+			return PublishResult{}, err
+		}
+		return PublishResult{Status: PublishCreated, Description: entry.PreviousDescription, Milestones: entry.PreviousMilestones}, nil
+	}
+
+	existing, err := s.client.RetrieveRelease(entry.Tag)
+	if err != nil {
+		return PublishResult{}, err
+	}
+
+	if entry.Status == PublishCreated {
+		err = s.client.DeleteTagRelease(entry.Tag)
+		if err != nil {
+			return PublishResult{}, err
+		}
+		return PublishResult{Status: PublishDeleted, PreviousDescription: existing.Description, PreviousMilestones: existing.Milestones}, nil
+	}
+
+	body := Release{Name: entry.Tag, Description: entry.PreviousDescription}
+	result := PublishResult{Status: PublishRolledBack, PreviousDescription: existing.Description, Description: entry.PreviousDescription}
+	if len(entry.Milestones) > 0 {
+		// An empty list removes the milestones the entry set.
+		body.Milestones = append([]string{}, entry.PreviousMilestones...)
+		result.PreviousMilestones, result.Milestones = existing.Milestones, body.Milestones
+	}
+	err = s.client.UpdateTagRelease(body)
+	if err != nil {
+		return PublishResult{}, err
+	}
+	return result, nil
+}
+
+// reconcileReleaseLinks makes the asset links of an existing release match the
+// configured ones by name: missing links are added, changed links are updated
+// and the other links are removed. Links are left untouched when none are configured.
+func (s *gitLabService) reconcileReleaseLinks(tagName string) error {
+	if len(s.config.AssetLinks) == 0 {
+		return nil
+	}
+
+	existingLinks, err := s.retrieveReleaseLinks(tagName)
+	if err != nil {
+		return err
+	}
+
+	existingByName := make(map[string]ReleaseLink)
+	for _, link := range existingLinks {
+		existingByName[link.Name] = link
+	}
+
+	for _, link := range s.config.AssetLinks {
+		existing, exists := existingByName[link.Name]
+		delete(existingByName, link.Name)
+		if !exists {
+			if err := s.client.CreateReleaseLink(tagName, link); err != nil {
+				return err
+			}
+			continue
+		}
+
+		link.ID = existing.ID
+		if !link.equal(existing) {
+			if err := s.client.UpdateReleaseLink(tagName, link); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, link := range existingLinks {
+		if _, stale := existingByName[link.Name]; stale {
+			if err := s.client.DeleteReleaseLink(tagName, link.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func (s *gitLabService) RetrieveChangelogsByStartAndEndDate(startDate, endDate time.Time) ([]MergeRequest, []Issue, error) {
+func (s *gitLabService) retrieveReleaseLinks(tagName string) ([]ReleaseLink, error) {
+	var pg Pagination
+	pg.SetDefaults()
+	var resp []ReleaseLink
+	links, err := s.client.RetrieveReleaseLinks(tagName, &pg)
+	if err != nil {
+		return nil, err
+	}
+	resp = append(resp, links...)
+
+	for pg.Page != GitLabDefaultPage {
+		links, err := s.client.RetrieveReleaseLinks(tagName, &pg)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, links...)
+	}
+	return resp, nil
+}
+
+func (s *gitLabService) RetrieveRepo() (Repo, error) {
+	return s.client.RetrieveRepo()
+}
+
+func (s *gitLabService) RetrieveChangelogs(latestTag, previousTag Tag) ([]MergeRequest, []Issue, error) {
+	startDate := previousTag.Commit.CommittedDate
+	endDate := latestTag.Commit.CommittedDate
+	s.logger.Debug("Retrieving changelogs", "from", startDate, "to", endDate)
 	mrs, err := s.retrieveMergeRequests(ListMReqParams{
 		TargetBranch:  s.config.TargetBranch,
 		UpdatedBefore: endDate,
@@ -64,16 +340,15 @@ func (s *gitLabService) RetrieveChangelogsByStartAndEndDate(startDate, endDate t
 	for _, mr := range mrs {
 		if mr.MergedAt.After(startDate) && mr.MergedAt.Before(endDate) {
 			filteredMRs = append(filteredMRs, mr)
+			continue
 		}
+		s.logger.Debug("Skipped merge request merged out of the tag range", "iid", mr.IID, "merged_at", mr.MergedAt)
 	}
+	s.logger.Info("Retrieved merge requests", "updated", len(mrs), "merged", len(filteredMRs))
 
-	if s.config.IncludeCommits {
-		for i, mr := range filteredMRs {
-			filteredMRs[i].Commits, err = s.retrieveMergeRequestCommits(mr.IID)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
+	filteredMRs, err = s.RetrieveMergeRequestDetails(filteredMRs, latestTag)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	issues, err := s.retrieveIssues(ListIssueParams{
@@ -89,13 +364,182 @@ func (s *gitLabService) RetrieveChangelogsByStartAndEndDate(startDate, endDate t
 	for _, iss := range issues {
 		if iss.ClosedAt.After(startDate) && iss.ClosedAt.Before(endDate) {
 			filteredISs = append(filteredISs, iss)
+			continue
 		}
+		s.logger.Debug("Skipped issue closed out of the tag range", "iid", iss.IID, "closed_at", iss.ClosedAt)
 	}
+	s.logger.Info("Retrieved issues", "updated", len(issues), "closed", len(filteredISs))
 
 	return filteredMRs, filteredISs, nil
 }
 
+// RetrieveMergeRequestDetails retrieves the commits and changes of the merge
+// requests when configured, and filters them by the included paths and the
+// package of the tag.
+func (s *gitLabService) RetrieveMergeRequestDetails(mrs []MergeRequest, tag Tag) ([]MergeRequest, error) {
+	var err error
+	if s.config.IncludeCommits {
+		for i, mr := range mrs {
+			mrs[i].Commits, err = s.retrieveMergeRequestCommits(mr.IID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.config.IncludeChanges {
+		for i, mr := range mrs {
+			mrs[i].Changes, err = s.retrieveMergeRequestChanges(mr.IID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		mrs, err = s.filterMergeRequestsByPaths(mrs)
+		if err != nil {
+			return nil, err
+		}
+
+		if tag.Package != "" {
+			mrs, err = s.filterMergeRequestsByPackage(mrs, tag.Package)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return mrs, nil
+}
+
+// RetrieveTagAndPreviousTag finds the tag by name and the previous tag
+// matching the target tag regex on the target branch. The project creation
+// date is used when there is no previous tag.
+func (s *gitLabService) RetrieveTagAndPreviousTag(tagName string) ([]Tag, error) {
+	regex, err := regexp.Compile(s.config.TargetTagRegex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var pg Pagination
+	pg.SetDefaults()
+	var tag, previous Tag
+	lookingLimit := lookingSecondTagLimit
+	for previous.Name == "" && lookingLimit > 0 {
+		tags, err := s.client.RetrieveTags(&pg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range tags {
+			if s.config.Monorepo {
+				t.Package = PackageOfTag(regex, t.Name)
+			}
+			if tag.Name == "" {
+				if t.Name == tagName {
+					tag = t
+				}
+				continue
+			}
+
+			if !regex.MatchString(t.Name) || t.Package != tag.Package {
+				continue
+			}
+
+			commits, err := s.client.RetrieveCommitRefsBySHA(t.Commit.ID, url.Values{"type": {"branch"}})
+			if err != nil {
+				return nil, err
+			}
+			if s.isInTargetBranch(commits) {
+				previous = t
+				break
+			}
+			s.logger.Debug("Skipped tag not on the target branch", "tag", t.Name)
+		}
+
+		if pg.Page == GitLabDefaultPage {
+			break
+		}
+		lookingLimit -= 1
+	}
+
+	if tag.Name == "" {
+		return nil, errors.WithNotFound(errors.Errorf("Cannot find tag %q.", tagName), TagNotFoundCode)
+	}
+
+	if previous.Name == "" {
+		repo, err := s.client.RetrieveRepo()
+		if err != nil {
+			return nil, err
+		}
+		previous = Tag{
+			Package: tag.Package,
+			Commit: Commit{
+				CommittedDate: repo.CreatedAt,
+			},
+		}
+	}
+
+	return s.shiftTagDates(tag, previous), nil
+}
+
+// RetrieveTagRange finds the tags bounding a release note, the previous tag
+// defaults to the one found by RetrieveTagAndPreviousTag.
+func (s *gitLabService) RetrieveTagRange(tagName, previousTagName string) ([]Tag, error) {
+	if previousTagName == "" {
+		return s.RetrieveTagAndPreviousTag(tagName)
+	}
+
+	tag, err := s.retrieveTag(tagName)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.retrieveTag(previousTagName)
+	if err != nil {
+		return nil, err
+	}
+	if !previous.Commit.CommittedDate.Before(tag.Commit.CommittedDate) {
+		return nil, errors.WithCode(errors.Errorf("Tag %q is not older than tag %q.", previousTagName, tagName), InvalidTagRangeCode)
+	}
+	return s.shiftTagDates(tag, previous), nil
+}
+
+// IsTagInTargetBranch reports whether the commit of the tag is on the target
+// branch.
+func (s *gitLabService) IsTagInTargetBranch(tagName string) (bool, error) {
+	tag, err := s.retrieveTag(tagName)
+	if err != nil {
+		return false, err
+	}
+	commits, err := s.client.RetrieveCommitRefsBySHA(tag.Commit.ID, url.Values{"type": {"branch"}})
+	if err != nil {
+		return false, err
+	}
+	return s.isInTargetBranch(commits), nil
+}
+
+func (s *gitLabService) retrieveTag(tagName string) (Tag, error) {
+	tag, err := s.client.RetrieveTag(tagName)
+	if errors.IsNotFound(err) {
+		return Tag{}, errors.WithNotFound(errors.Errorf("Cannot find tag %q.", tagName), TagNotFoundCode)
+	}
+	if err != nil {
+		return Tag{}, err
+	}
+
+	if s.config.Monorepo {
+		regex, err := regexp.Compile(s.config.TargetTagRegex)
+		if err != nil {
+			return Tag{}, errors.WithStack(err)
+		}
+		tag.Package = PackageOfTag(regex, tag.Name)
+	}
+	return tag, nil
+}
+
 func (s *gitLabService) RetrieveTwoLatestTags() ([]Tag, error) {
+	if s.config.Monorepo {
+		return s.retrieveTwoLatestPackageTags()
+	}
+
 	var pg Pagination
 	pg.SetDefaults()
 	tags, err := s.client.RetrieveTags(&pg)
@@ -125,11 +569,11 @@ func (s *gitLabService) RetrieveTwoLatestTags() ([]Tag, error) {
 			return nil, err
 		}
 
-		return []Tag{latest, {
+		return s.shiftTagDates(latest, Tag{
 			Commit: Commit{
 				CommittedDate: repo.CreatedAt,
 			},
-		}}, nil
+		}), nil
 	}
 
 	var secondTag Tag
@@ -150,6 +594,7 @@ func (s *gitLabService) RetrieveTwoLatestTags() ([]Tag, error) {
 				secondTag = tag
 				break
 			}
+			s.logger.Debug("Skipped tag not on the target branch", "tag", tag.Name)
 		}
 
 		if secondTag.Name == "" && pg.Page != GitLabDefaultPage {
@@ -166,13 +611,116 @@ func (s *gitLabService) RetrieveTwoLatestTags() ([]Tag, error) {
 		return nil, errors.New("Cannot find latest and second latest tag. Abort the program!")
 	}
 
+	return s.shiftTagDates(latest, secondTag), nil
+}
+
+// retrieveTwoLatestPackageTags finds the latest tag of the target package and
+// the previous tag of the same package, skipping the tags of other packages.
+// The project creation date is used when the package has a single tag.
+func (s *gitLabService) retrieveTwoLatestPackageTags() ([]Tag, error) {
+	regex, err := regexp.Compile(s.config.TargetTagRegex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if regex.NumSubexp() < 1 {
+		return nil, errors.New("Monorepo mode requires a capture group in the target tag regex.")
+	}
+
+	var pg Pagination
+	pg.SetDefaults()
+	var latest, previous Tag
+	lookingLimit := lookingSecondTagLimit
+	for previous.Name == "" && lookingLimit > 0 {
+		tags, err := s.client.RetrieveTags(&pg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			tag.Package = PackageOfTag(regex, tag.Name)
+			if tag.Package == "" {
+				continue
+			}
+
+			targetPackage := s.config.TargetPackage
+			if latest.Name != "" {
+				targetPackage = latest.Package
+			}
+			if targetPackage != "" && tag.Package != targetPackage {
+				continue
+			}
+
+			commits, err := s.client.RetrieveCommitRefsBySHA(tag.Commit.ID, url.Values{"type": {"branch"}})
+			if err != nil {
+				return nil, err
+			}
+			if !s.isInTargetBranch(commits) {
+				s.logger.Debug("Skipped tag not on the target branch", "tag", tag.Name)
+				continue
+			}
+
+			if latest.Name == "" {
+				latest = tag
+				continue
+			}
+			previous = tag
+			break
+		}
+
+		if pg.Page == GitLabDefaultPage {
+			break
+		}
+		lookingLimit -= 1
+	}
+
+	if latest.Name == "" {
+		return nil, errors.Errorf("Cannot find any tag of package %q. Abort the program!", s.config.TargetPackage)
+	}
+
+	if previous.Name == "" {
+		repo, err := s.client.RetrieveRepo()
+		if err != nil {
+			return nil, err
+		}
+		previous = Tag{
+			Package: latest.Package,
+			Commit: Commit{
+				CommittedDate: repo.CreatedAt,
+			},
+		}
+	}
+
+	return s.shiftTagDates(latest, previous), nil
+}
+
+// PackageOfTag returns the package captured by the regex, or an empty string
+// when the tag doesn't match.
+func PackageOfTag(regex *regexp.Regexp, name string) string {
+	match := regex.FindStringSubmatch(name)
+	if match == nil {
+		return ""
+	}
+	if i := regex.SubexpIndex(packageRegexGroup); i > 0 {
+		return match[i]
+	}
+	return match[1]
+}
+
+// shiftTagDates shifts the dates of the chosen tags by IssueClosedSeconds.
+func (s *gitLabService) shiftTagDates(latest, previous Tag) []Tag {
 	if s.config.IssueClosedSeconds > 0 {
 		addedTime := time.Duration(s.config.IssueClosedSeconds) * time.Second
 		latest.Commit.CommittedDate = latest.Commit.CommittedDate.Add(addedTime)
-		secondTag.Commit.CommittedDate = secondTag.Commit.CommittedDate.Add(addedTime)
+		previous.Commit.CommittedDate = previous.Commit.CommittedDate.Add(addedTime)
 	}
 
-	return []Tag{latest, secondTag}, nil
+	attrs := []interface{}{"tag", latest.Name, "previous_tag", previous.Name,
+		"from", previous.Commit.CommittedDate, "to", latest.Commit.CommittedDate}
+	if s.config.Monorepo {
+		attrs = append(attrs, "package", latest.Package)
+	}
+	s.logger.Info("Chose tags", attrs...)
+	return []Tag{latest, previous}
 }
 
 func (s *gitLabService) retrieveMergeRequests(prs ListMReqParams) ([]MergeRequest, error) {
@@ -185,13 +733,16 @@ func (s *gitLabService) retrieveMergeRequests(prs ListMReqParams) ([]MergeReques
 	}
 	resp = append(resp, mrs...)
 
+	pages := 1
 	for pg.Page != GitLabDefaultPage {
 		mrs, err := s.client.RetrieveMergeRequests(prs, &pg)
 		if err != nil {
 			return nil, err
 		}
 		resp = append(resp, mrs...)
+		pages++
 	}
+	s.logger.Debug("Retrieved merge request pages", "pages", pages, "count", len(resp))
 	return resp, err
 }
 
@@ -212,6 +763,26 @@ func (s *gitLabService) retrieveMergeRequestCommits(merge_request_iid int) ([]MR
 	return resp, err
 }
 
+func (s *gitLabService) retrieveMergeRequestChanges(merge_request_iid int) ([]MRChange, error) {
+	var pg Pagination
+	pg.SetDefaults()
+	var resp []MRChange
+	changes, err := s.client.RetrieveMergeRequestChanges(merge_request_iid, &pg)
+	if err != nil {
+		return nil, err
+	}
+	resp = append(resp, changes...)
+
+	for pg.Page != GitLabDefaultPage {
+		changes, err := s.client.RetrieveMergeRequestChanges(merge_request_iid, &pg)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, changes...)
+	}
+	return resp, nil
+}
+
 func (s *gitLabService) retrieveIssues(prs ListIssueParams) ([]Issue, error) {
 	var pg Pagination
 	pg.SetDefaults()
@@ -222,16 +793,84 @@ func (s *gitLabService) retrieveIssues(prs ListIssueParams) ([]Issue, error) {
 	}
 	resp = append(resp, issues...)
 
+	pages := 1
 	for pg.Page != GitLabDefaultPage {
 		issues, err := s.client.RetrieveIssues(prs, &pg)
 		if err != nil {
 			return nil, err
 		}
 		resp = append(resp, issues...)
+		pages++
 	}
+	s.logger.Debug("Retrieved issue pages", "pages", pages, "count", len(resp))
 	return resp, err
 }
 
+func (s *gitLabService) filterMergeRequestsByPaths(mrs []MergeRequest) ([]MergeRequest, error) {
+	if len(s.config.IncludePaths) == 0 && len(s.config.IncludeComponents) == 0 {
+		return mrs, nil
+	}
+
+	var patterns []*PathPattern
+	for _, p := range s.config.IncludePaths {
+		pattern, err := CompilePathPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	var filteredMRs []MergeRequest
+	for _, mr := range mrs {
+		paths := mr.ChangedPaths()
+		if MatchAnyPath(patterns, paths) || s.isInIncludedComponents(paths) {
+			filteredMRs = append(filteredMRs, mr)
+			continue
+		}
+		s.logger.Debug("Filtered out merge request changing no included path or component", "iid", mr.IID)
+	}
+	s.logger.Info("Filtered merge requests by path", "kept", len(filteredMRs), "filtered", len(mrs)-len(filteredMRs))
+	return filteredMRs, nil
+}
+
+func (s *gitLabService) filterMergeRequestsByPackage(mrs []MergeRequest, packageName string) ([]MergeRequest, error) {
+	packagePath, exists := s.config.PackagePaths[packageName]
+	if !exists {
+		template := s.config.PackagePathTemplate
+		if template == "" {
+			template = defaultPackagePathTemplate
+		}
+		packagePath = strings.ReplaceAll(template, packagePathTemplateKey, packageName)
+	}
+
+	pattern, err := CompilePathPattern(packagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredMRs []MergeRequest
+	for _, mr := range mrs {
+		if MatchAnyPath([]*PathPattern{pattern}, mr.ChangedPaths()) {
+			filteredMRs = append(filteredMRs, mr)
+			continue
+		}
+		s.logger.Debug("Filtered out merge request changing no file of the package", "iid", mr.IID, "package", packageName)
+	}
+	s.logger.Info("Filtered merge requests by package", "package", packageName, "kept", len(filteredMRs), "filtered", len(mrs)-len(filteredMRs))
+	return filteredMRs, nil
+}
+
+func (s *gitLabService) isInIncludedComponents(paths []string) bool {
+	for _, component := range s.config.ComponentRules.ComponentsOf(paths) {
+		for _, included := range s.config.IncludeComponents {
+			if component == included {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *gitLabService) isMatchTargetTagRegex(tag Tag) (bool, error) {
 	regex, err := regexp.Compile(s.config.TargetTagRegex)
 	if err != nil {
@@ -257,10 +896,21 @@ type GitLabClient interface {
 	RetrieveRepo() (Repo, error)
 	RetrieveMergeRequests(prs ListMReqParams, pg *Pagination) ([]MergeRequest, error)
 	RetrieveMergeRequestCommits(merge_request_iid int, pg *Pagination) ([]MRCommit, error)
+	RetrieveMergeRequestChanges(merge_request_iid int, pg *Pagination) ([]MRChange, error)
 	RetrieveTags(pg *Pagination) ([]Tag, error)
+	RetrieveTag(tagName string) (Tag, error)
 	RetrieveCommitRefsBySHA(sha string, query url.Values) ([]CommitRef, error)
+	RetrieveRelease(tagName string) (Release, error)
 	CreateTagRelease(body Release) error
 	UpdateTagRelease(body Release) error
+	DeleteTagRelease(tagName string) error
+	RetrieveReleaseLinks(tagName string, pg *Pagination) ([]ReleaseLink, error)
+	CreateReleaseLink(tagName string, link ReleaseLink) error
+	UpdateReleaseLink(tagName string, link ReleaseLink) error
+	DeleteReleaseLink(tagName string, linkID int) error
+	RetrieveWikiPage(slug string) (WikiPage, error)
+	CreateWikiPage(page WikiPage) error
+	UpdateWikiPage(slug string, page WikiPage) error
 }
 
 type ListIssueParams struct {
@@ -270,15 +920,28 @@ type ListIssueParams struct {
 }
 
 type Issue struct {
-	IID      int       `json:"iid"`
-	Title    string    `json:"title"`
-	WebURL   string    `json:"web_url"`
-	Labels   []string  `json:"labels"`
-	ClosedAt time.Time `json:"closed_at"`
+	IID       int      `json:"iid"`
+	ProjectID int      `json:"project_id"`
+	Title     string   `json:"title"`
+	WebURL    string   `json:"web_url"`
+	Labels    []string `json:"labels"`
+	Author    struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	Milestone *Milestone `json:"milestone"`
+	ClosedAt  time.Time  `json:"closed_at"`
+}
+
+type Milestone struct {
+	Title string `json:"title"`
 }
 
 type Repo struct {
-	CreatedAt time.Time `json:"created_at"`
+	ID                int       `json:"id"`
+	Name              string    `json:"name"`
+	PathWithNamespace string    `json:"path_with_namespace"`
+	WebURL            string    `json:"web_url"`
+	CreatedAt         time.Time `json:"created_at"`
 }
 
 type ListMReqParams struct {
@@ -289,22 +952,40 @@ type ListMReqParams struct {
 }
 
 type MergeRequest struct {
-	IID    int      `json:"iid"`
-	Title  string   `json:"title"`
-	WebURL string   `json:"web_url"`
-	Labels []string `json:"labels"`
-	Author struct {
+	IID       int      `json:"iid"`
+	ProjectID int      `json:"project_id"`
+	Title     string   `json:"title"`
+	WebURL    string   `json:"web_url"`
+	Labels    []string `json:"labels"`
+	Author    struct {
 		Username string `json:"username"`
 		WebURL   string `json:"web_url"`
 	} `json:"author"`
-	MergedAt time.Time `json:"merged_at"`
-	Commits  []MRCommit
+	Milestone *Milestone `json:"milestone"`
+	MergedAt  time.Time  `json:"merged_at"`
+	Commits   []MRCommit
+	Changes   []MRChange
+}
+
+// ChangedPaths returns the paths touched by the merge request, including the
+// old path of renamed files.
+func (mr MergeRequest) ChangedPaths() []string {
+	var paths []string
+	for _, change := range mr.Changes {
+		paths = append(paths, change.NewPath)
+		if change.RenamedFile && change.OldPath != change.NewPath {
+			paths = append(paths, change.OldPath)
+		}
+	}
+	return paths
 }
 
 type Tag struct {
 	Name    string  `json:"name"`
 	Commit  Commit  `json:"commit"`
 	Release Release `json:"release"`
+	// Package is the package of the tag in monorepo mode.
+	Package string `json:"-"`
 }
 
 type Commit struct {
@@ -317,8 +998,59 @@ type CommitRef struct {
 }
 
 type Release struct {
-	Name        string `json:"tag_name"`
-	Description string `json:"description"`
+	Name        string         `json:"tag_name"`
+	Description string         `json:"description"`
+	Assets      *ReleaseAssets `json:"assets,omitempty"`
+	Milestones  []string       `json:"milestones,omitempty"`
+}
+
+type ReleaseAssets struct {
+	Links []ReleaseLink `json:"links"`
+}
+
+type ReleaseLink struct {
+	ID              int    `json:"id,omitempty" mapstructure:"-"`
+	Name            string `json:"name" mapstructure:"name"`
+	URL             string `json:"url" mapstructure:"url"`
+	LinkType        string `json:"link_type,omitempty" mapstructure:"link_type"`
+	DirectAssetPath string `json:"direct_asset_path,omitempty" mapstructure:"direct_asset_path"`
+	// DirectAssetURL is returned by GitLab instead of DirectAssetPath.
+	DirectAssetURL string `json:"direct_asset_url,omitempty" mapstructure:"-"`
+}
+
+// Validate checks the fields required by the release links API.
+func (l ReleaseLink) Validate() error {
+	if l.Name == "" || l.URL == "" {
+		return errors.Errorf("Release link %q must have a name and an url.", l.Name)
+	}
+
+	switch l.LinkType {
+	case "", "other", "runbook", "image", "package":
+	default:
+		return errors.Errorf("Unsupported link type %q of release link %q.", l.LinkType, l.Name)
+	}
+	return nil
+}
+
+// equal compares a configured link with an existing one. The direct asset
+// of an existing link is ignored when the configured link has none, an update
+// omitting it cannot remove it.
+func (l ReleaseLink) equal(existing ReleaseLink) bool {
+	linkType, existingLinkType := l.LinkType, existing.LinkType
+	if linkType == "" {
+		linkType = "other"
+	}
+	if existingLinkType == "" {
+		existingLinkType = "other"
+	}
+	if l.URL != existing.URL || linkType != existingLinkType {
+		return false
+	}
+
+	if l.DirectAssetPath == "" {
+		return true
+	}
+	return strings.HasSuffix(existing.DirectAssetURL, "/downloads/"+strings.TrimPrefix(l.DirectAssetPath, "/"))
 }
 
 type Pagination struct {
@@ -350,3 +1082,19 @@ type MRCommit struct {
 	CommittedDate  time.Time `json:"committed_date"`
 	WebURL         string    `json:"web_url"`
 }
+
+type MRChange struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+type WikiPage struct {
+	// Slug is the path of the page, derived from its title by GitLab.
+	Slug    string `json:"slug,omitempty"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Format  string `json:"format,omitempty"`
+}
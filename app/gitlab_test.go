@@ -0,0 +1,487 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClient is an in-memory GitLab project, the tags are listed newest
+// first by pages of perPage and the writes are recorded in calls.
+type fakeClient struct {
+	repo     Repo
+	tags     []Tag
+	perPage  int
+	branches map[string][]string
+	changes  map[int][]MRChange
+	releases map[string]Release
+	links    []ReleaseLink
+	// errs fails the calls by method name.
+	errs  map[string]error
+	calls []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		perPage:  GitLabDefaultPerPage,
+		branches: make(map[string][]string),
+		changes:  make(map[int][]MRChange),
+		releases: make(map[string]Release),
+		errs:     make(map[string]error),
+	}
+}
+
+// addTag appends a tag older than the previous ones, on the branch.
+func (c *fakeClient) addTag(name, branch string) Tag {
+	id := fmt.Sprintf("sha-%d", len(c.tags))
+	date := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(len(c.tags)) * 24 * time.Hour)
+	tag := Tag{Name: name, Commit: Commit{ID: id, CommittedDate: date}}
+	c.tags = append(c.tags, tag)
+	c.branches[id] = []string{branch}
+	return tag
+}
+
+func (c *fakeClient) call(name string, args ...interface{}) error {
+	c.calls = append(c.calls, strings.TrimSpace(fmt.Sprintln(append([]interface{}{name}, args...)...)))
+	return c.errs[name]
+}
+
+// page returns the items of the page and moves pg to the next one.
+func page[T any](items []T, pg *Pagination, perPage int) []T {
+	start := (pg.Page - 1) * perPage
+	end := start + perPage
+	pg.Page = pg.Page + 1
+	if end >= len(items) {
+		end = len(items)
+		pg.Page = GitLabDefaultPage
+	}
+	if start >= len(items) {
+		return nil
+	}
+	return items[start:end]
+}
+
+func (c *fakeClient) RetrieveIssues(prs ListIssueParams, pg *Pagination) ([]Issue, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) RetrieveRepo() (Repo, error) {
+	return c.repo, nil
+}
+
+func (c *fakeClient) RetrieveMergeRequests(prs ListMReqParams, pg *Pagination) ([]MergeRequest, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) RetrieveMergeRequestCommits(iid int, pg *Pagination) ([]MRCommit, error) {
+	return nil, nil
+}
+
+func (c *fakeClient) RetrieveMergeRequestChanges(iid int, pg *Pagination) ([]MRChange, error) {
+	return page(c.changes[iid], pg, c.perPage), nil
+}
+
+func (c *fakeClient) RetrieveTags(pg *Pagination) ([]Tag, error) {
+	if err := c.call("RetrieveTags", pg.Page); err != nil {
+		return nil, err
+	}
+	return page(c.tags, pg, c.perPage), nil
+}
+
+func (c *fakeClient) RetrieveTag(tagName string) (Tag, error) {
+	for _, tag := range c.tags {
+		if tag.Name == tagName {
+			return tag, nil
+		}
+	}
+	return Tag{}, errors.WithNotFound(errors.New("404 Tag Not Found"), "gitlab_not_found")
+}
+
+func (c *fakeClient) RetrieveCommitRefsBySHA(sha string, query url.Values) ([]CommitRef, error) {
+	var refs []CommitRef
+	for _, branch := range c.branches[sha] {
+		refs = append(refs, CommitRef{Name: branch})
+	}
+	return refs, nil
+}
+
+func (c *fakeClient) RetrieveRelease(tagName string) (Release, error) {
+	if err := c.call("RetrieveRelease", tagName); err != nil {
+		return Release{}, err
+	}
+	release, exists := c.releases[tagName]
+	if !exists {
+		return Release{}, errors.WithNotFound(errors.New("404 Release Not Found"), "gitlab_not_found")
+	}
+	return release, nil
+}
+
+func (c *fakeClient) CreateTagRelease(body Release) error {
+	if err := c.call("CreateTagRelease", body.Name); err != nil {
+		return err
+	}
+	c.releases[body.Name] = body
+	return nil
+}
+
+func (c *fakeClient) UpdateTagRelease(body Release) error {
+	if err := c.call("UpdateTagRelease", body.Name); err != nil {
+		return err
+	}
+	c.releases[body.Name] = body
+	return nil
+}
+
+func (c *fakeClient) DeleteTagRelease(tagName string) error {
+	if err := c.call("DeleteTagRelease", tagName); err != nil {
+		return err
+	}
+	delete(c.releases, tagName)
+	return nil
+}
+
+func (c *fakeClient) RetrieveReleaseLinks(tagName string, pg *Pagination) ([]ReleaseLink, error) {
+	return page(c.links, pg, c.perPage), nil
+}
+
+func (c *fakeClient) CreateReleaseLink(tagName string, link ReleaseLink) error {
+	return c.call("CreateReleaseLink", link.Name)
+}
+
+func (c *fakeClient) UpdateReleaseLink(tagName string, link ReleaseLink) error {
+	return c.call("UpdateReleaseLink", link.Name)
+}
+
+func (c *fakeClient) DeleteReleaseLink(tagName string, linkID int) error {
+	return c.call("DeleteReleaseLink", linkID)
+}
+
+func (c *fakeClient) RetrieveWikiPage(slug string) (WikiPage, error) {
+	return WikiPage{}, nil
+}
+
+func (c *fakeClient) CreateWikiPage(page WikiPage) error {
+	return nil
+}
+
+func (c *fakeClient) UpdateWikiPage(slug string, page WikiPage) error {
+	return nil
+}
+
+func tagNames(tags []Tag) []string {
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func TestPackageOfTag(t *testing.T) {
+	tcs := []struct {
+		regex string
+		tag   string
+		pkg   string
+	}{
+		{`^(\w+)-v\d+\.\d+\.\d+$`, "billing-v1.2.0", "billing"},
+		{`^(?P<version>v\d+)/(?P<package>\w+)$`, "v2/web", "web"},
+		{`^(\w+)-v\d+\.\d+\.\d+$`, "v1.2.0", ""},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tag, func(t *testing.T) {
+			assert.Equal(t, tc.pkg, PackageOfTag(regexp.MustCompile(tc.regex), tc.tag))
+		})
+	}
+}
+
+func TestRetrieveTwoLatestTags_Monorepo(t *testing.T) {
+	client := newFakeClient()
+	client.perPage = 2
+	client.addTag("web-v2.0.0", "main")
+	client.addTag("billing-v1.1.0", "main")
+	client.addTag("billing-v1.0.1", "hotfix")
+	client.addTag("web-v1.0.0", "main")
+	client.addTag("billing-v1.0.0", "main")
+	client.addTag("v0.9.0", "main")
+
+	tcs := []struct {
+		name          string
+		targetPackage string
+		tags          []string
+	}{
+		{"latest package", "", []string{"web-v2.0.0", "web-v1.0.0"}},
+		{"target package on the next pages", "billing", []string{"billing-v1.1.0", "billing-v1.0.0"}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewGitLabService(client, Config{
+				TargetBranch:   "main",
+				TargetTagRegex: `^(\w+)-v\d+\.\d+\.\d+$`,
+				Monorepo:       true,
+				TargetPackage:  tc.targetPackage,
+			})
+
+			tags, err := svc.RetrieveTwoLatestTags()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.tags, tagNames(tags))
+			assert.Equal(t, tags[0].Package, tags[1].Package)
+		})
+	}
+}
+
+func TestRetrieveTwoLatestTags_MonorepoSinglePackageTag(t *testing.T) {
+	client := newFakeClient()
+	client.repo.CreatedAt = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	client.addTag("web-v1.0.0", "main")
+	client.addTag("billing-v1.0.0", "main")
+
+	svc := NewGitLabService(client, Config{TargetBranch: "main", TargetTagRegex: `^(\w+)-v.*$`, Monorepo: true})
+	tags, err := svc.RetrieveTwoLatestTags()
+	assert.NoError(t, err)
+	assert.Equal(t, "web-v1.0.0", tags[0].Name)
+	assert.Equal(t, Tag{Package: "web", Commit: Commit{CommittedDate: client.repo.CreatedAt}}, tags[1])
+}
+
+func TestRetrieveTwoLatestTags_MonorepoErrors(t *testing.T) {
+	client := newFakeClient()
+	client.addTag("web-v1.0.0", "main")
+
+	_, err := NewGitLabService(client, Config{TargetTagRegex: `^\w+-v.*$`, Monorepo: true}).RetrieveTwoLatestTags()
+	assert.EqualError(t, err, "Monorepo mode requires a capture group in the target tag regex.")
+
+	_, err = NewGitLabService(client, Config{TargetTagRegex: `^(\w+)-v.*$`, Monorepo: true, TargetPackage: "docs"}).RetrieveTwoLatestTags()
+	assert.EqualError(t, err, `Cannot find any tag of package "docs". Abort the program!`)
+}
+
+func TestRetrieveMergeRequestDetails_FiltersByPackage(t *testing.T) {
+	client := newFakeClient()
+	client.changes[1] = []MRChange{{NewPath: "services/billing/main.go"}}
+	client.changes[2] = []MRChange{{NewPath: "web/index.ts"}}
+	client.changes[3] = []MRChange{{OldPath: "billing/old.go", NewPath: "web/new.ts", RenamedFile: true}}
+	mrs := []MergeRequest{{IID: 1}, {IID: 2}, {IID: 3}}
+
+	tcs := []struct {
+		name   string
+		config Config
+		pkg    string
+		iids   []int
+	}{
+		{"path of the package", Config{PackagePaths: map[string]string{"billing": "/services/billing/"}}, "billing", []int{1}},
+		{"default template", Config{}, "web", []int{2, 3}},
+		{"custom template", Config{PackagePathTemplate: "/{package}/"}, "billing", []int{3}},
+		{"not a package", Config{}, "", []int{1, 2, 3}},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			// Monorepo retrieves the changes without IncludeChanges.
+			tc.config.Monorepo = true
+			svc := NewGitLabService(client, tc.config)
+
+			filtered, err := svc.RetrieveMergeRequestDetails(append([]MergeRequest(nil), mrs...), Tag{Package: tc.pkg})
+			assert.NoError(t, err)
+			var filteredIIDs []int
+			for _, mr := range filtered {
+				filteredIIDs = append(filteredIIDs, mr.IID)
+			}
+			assert.Equal(t, tc.iids, filteredIIDs)
+		})
+	}
+}
+
+func TestPublish_ReconcilesReleaseLinks(t *testing.T) {
+	client := newFakeClient()
+	client.perPage = 2
+	client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: wrapGeneratedContent("note")}
+	client.links = []ReleaseLink{
+		{ID: 1, Name: "binary", URL: "https://example.com/v1/binary", LinkType: "package"},
+		{ID: 2, Name: "docs", URL: "https://example.com/old-docs"},
+		{ID: 3, Name: "stale", URL: "https://example.com/stale"},
+		// The direct asset url of an existing link is kept by the updates.
+		{ID: 4, Name: "image", URL: "https://example.com/image", LinkType: "image", DirectAssetURL: "https://gitlab.example.com/-/releases/v1.0.0/downloads/image"},
+	}
+
+	svc := NewGitLabService(client, Config{AssetLinks: []ReleaseLink{
+		{Name: "binary", URL: "https://example.com/v1/binary", LinkType: "package"},
+		{Name: "docs", URL: "https://example.com/docs"},
+		{Name: "image", URL: "https://example.com/image", LinkType: "image"},
+		{Name: "checksums", URL: "https://example.com/checksums"},
+	}})
+
+	result, err := svc.Publish(Tag{Name: "v1.0.0", Release: Release{Name: "v1.0.0"}}, "note", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, PublishUnchanged, result.Status)
+	assert.Equal(t, []string{
+		"RetrieveRelease v1.0.0",
+		"UpdateReleaseLink docs",
+		"CreateReleaseLink checksums",
+		"DeleteReleaseLink 3",
+	}, client.calls)
+}
+
+func TestPublish_ReleaseLinksUntouchedWithoutConfig(t *testing.T) {
+	client := newFakeClient()
+	client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: wrapGeneratedContent("note")}
+	client.links = []ReleaseLink{{ID: 1, Name: "manual", URL: "https://example.com/manual"}}
+
+	_, err := NewGitLabService(client, Config{}).Publish(Tag{Name: "v1.0.0", Release: Release{Name: "v1.0.0"}}, "note", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"RetrieveRelease v1.0.0"}, client.calls)
+}
+
+func TestReleaseMilestones(t *testing.T) {
+	var fix, feature MergeRequest
+	fix.Milestone = &Milestone{Title: "2024.05"}
+	feature.Milestone = &Milestone{Title: "2024.06"}
+	mrs := []MergeRequest{fix, feature, {}, {Milestone: &Milestone{}}}
+	issues := []Issue{{Milestone: &Milestone{Title: "2024.05"}}, {Milestone: &Milestone{Title: "Backlog"}}}
+
+	tcs := []struct {
+		name       string
+		config     Config
+		milestones []string
+	}{
+		{"configured", Config{Milestones: []string{"1.0"}, InferMilestones: true}, []string{"1.0"}},
+		{"inferred", Config{InferMilestones: true}, []string{"2024.05", "2024.06", "Backlog"}},
+		{"not inferred", Config{}, nil},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.milestones, NewGitLabService(newFakeClient(), tc.config).ReleaseMilestones(mrs, issues))
+		})
+	}
+}
+
+func TestPublish_Milestones(t *testing.T) {
+	tcs := []struct {
+		name       string
+		existing   []string
+		milestones []string
+		status     PublishStatus
+	}{
+		{"same in another order", []string{"2024.05", "2024.06"}, []string{"2024.06", "2024.05"}, PublishUnchanged},
+		{"none configured", []string{"2024.05"}, nil, PublishUnchanged},
+		{"added", []string{"2024.05"}, []string{"2024.05", "2024.06"}, PublishUpdated},
+		{"replaced", []string{"2024.05"}, []string{"2024.06"}, PublishUpdated},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient()
+			client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: wrapGeneratedContent("note"), Milestones: tc.existing}
+
+			result, err := NewGitLabService(client, Config{}).Publish(Tag{Name: "v1.0.0", Release: Release{Name: "v1.0.0"}}, "note", tc.milestones)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, result.Status)
+			if tc.status == PublishUpdated {
+				assert.Equal(t, tc.milestones, client.releases["v1.0.0"].Milestones)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	tcs := []struct {
+		name     string
+		existing bool
+		entry    HistoryEntry
+		result   PublishResult
+		calls    []string
+		release  *Release
+	}{
+		{
+			name:     "created",
+			existing: true,
+			entry:    HistoryEntry{Tag: "v1.0.0", Status: PublishCreated, Description: "new note"},
+			result:   PublishResult{Status: PublishDeleted, PreviousDescription: "new note"},
+			calls:    []string{"RetrieveRelease v1.0.0", "DeleteTagRelease v1.0.0"},
+		},
+		{
+			name:     "updated",
+			existing: true,
+			entry:    HistoryEntry{Tag: "v1.0.0", Status: PublishUpdated, PreviousDescription: "old note", Description: "new note"},
+			result:   PublishResult{Status: PublishRolledBack, PreviousDescription: "new note", Description: "old note"},
+			calls:    []string{"RetrieveRelease v1.0.0", "UpdateTagRelease v1.0.0"},
+			release:  &Release{Name: "v1.0.0", Description: "old note"},
+		},
+		{
+			name:     "updated milestones",
+			existing: true,
+			entry:    HistoryEntry{Tag: "v1.0.0", Status: PublishUpdated, PreviousDescription: "old note", Description: "new note", Milestones: []string{"1.0"}},
+			result:   PublishResult{Status: PublishRolledBack, PreviousDescription: "new note", Description: "old note", Milestones: []string{}},
+			calls:    []string{"RetrieveRelease v1.0.0", "UpdateTagRelease v1.0.0"},
+			release:  &Release{Name: "v1.0.0", Description: "old note", Milestones: []string{}},
+		},
+		{
+			name:     "restored milestones",
+			existing: true,
+			entry:    HistoryEntry{Tag: "v1.0.0", Status: PublishUpdated, PreviousDescription: "old note", Description: "new note", PreviousMilestones: []string{"0.9"}, Milestones: []string{"1.0"}},
+			result:   PublishResult{Status: PublishRolledBack, PreviousDescription: "new note", Description: "old note", Milestones: []string{"0.9"}},
+			calls:    []string{"RetrieveRelease v1.0.0", "UpdateTagRelease v1.0.0"},
+			release:  &Release{Name: "v1.0.0", Description: "old note", Milestones: []string{"0.9"}},
+		},
+		{
+			name:    "deleted",
+			entry:   HistoryEntry{Tag: "v1.0.0", Status: PublishDeleted, PreviousDescription: "new note"},
+			result:  PublishResult{Status: PublishCreated, Description: "new note"},
+			calls:   []string{"CreateTagRelease v1.0.0"},
+			release: &Release{Name: "v1.0.0", Description: "new note"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newFakeClient()
+			if tc.existing {
+				client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: "new note"}
+			}
+
+			result, err := NewGitLabService(client, Config{}).Rollback(tc.entry)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.result, result)
+			assert.Equal(t, tc.calls, client.calls)
+			release, exists := client.releases["v1.0.0"]
+			if tc.release == nil {
+				assert.False(t, exists)
+			} else {
+				assert.Equal(t, *tc.release, release)
+			}
+		})
+	}
+}
+
+func TestPublish_SavesMilestones(t *testing.T) {
+	client := newFakeClient()
+	client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: wrapGeneratedContent("old note"), Milestones: []string{"0.9"}}
+
+	result, err := NewGitLabService(client, Config{}).Publish(Tag{Name: "v1.0.0", Release: Release{Name: "v1.0.0"}}, "new note", []string{"1.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0.9"}, result.PreviousMilestones)
+	assert.Equal(t, []string{"1.0"}, result.Milestones)
+}
+
+func TestRollback_MissingRelease(t *testing.T) {
+	_, err := NewGitLabService(newFakeClient(), Config{}).Rollback(HistoryEntry{Tag: "v1.0.0", Status: PublishUpdated})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestPublish_KeepsStatusWhenLinksFail(t *testing.T) {
+	client := newFakeClient()
+	client.releases["v1.0.0"] = Release{Name: "v1.0.0", Description: wrapGeneratedContent("old note")}
+	client.errs["CreateReleaseLink"] = errors.New("500 Internal Server Error")
+
+	svc := NewGitLabService(client, Config{AssetLinks: []ReleaseLink{{Name: "binary", URL: "https://example.com/binary"}}})
+	result, err := svc.Publish(Tag{Name: "v1.0.0", Release: Release{Name: "v1.0.0"}}, "new note", nil)
+	assert.EqualError(t, err, "500 Internal Server Error")
+	assert.Equal(t, PublishUpdated, result.Status)
+	assert.Equal(t, wrapGeneratedContent("old note"), result.PreviousDescription)
+}
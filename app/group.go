@@ -0,0 +1,152 @@
+package app
+
+import (
+	"time"
+)
+
+type GroupService interface {
+	RetrieveProjects() ([]Repo, error)
+	RetrieveChangelogs(releases []ProjectRelease) ([]ProjectRelease, error)
+}
+
+type groupService struct {
+	client GitLabGroupClient
+	config Config
+}
+
+func NewGroupService(client GitLabGroupClient, config Config) GroupService {
+	return &groupService{client: client, config: config}
+}
+
+func (s *groupService) RetrieveProjects() ([]Repo, error) {
+	var pg Pagination
+	pg.SetDefaults()
+	var resp []Repo
+	projects, err := s.client.RetrieveGroupProjects(&pg)
+	if err != nil {
+		return nil, err
+	}
+	resp = append(resp, projects...)
+
+	for pg.Page != GitLabDefaultPage {
+		projects, err := s.client.RetrieveGroupProjects(&pg)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, projects...)
+	}
+	return resp, nil
+}
+
+// RetrieveChangelogs retrieves the merge requests and issues of every project
+// release with the group endpoints over the union of the date ranges, then
+// keeps the ones within the range of their project.
+func (s *groupService) RetrieveChangelogs(releases []ProjectRelease) ([]ProjectRelease, error) {
+	if len(releases) == 0 {
+		return releases, nil
+	}
+
+	startDate, endDate := releases[0].PreviousTag.Commit.CommittedDate, releases[0].LatestTag.Commit.CommittedDate
+	for _, release := range releases[1:] {
+		if release.PreviousTag.Commit.CommittedDate.Before(startDate) {
+			startDate = release.PreviousTag.Commit.CommittedDate
+		}
+		if release.LatestTag.Commit.CommittedDate.After(endDate) {
+			endDate = release.LatestTag.Commit.CommittedDate
+		}
+	}
+
+	mrs, err := s.retrieveGroupMergeRequests(ListMReqParams{
+		TargetBranch:  s.config.TargetBranch,
+		UpdatedBefore: endDate,
+		UpdatedAfter:  startDate,
+		State:         mergeRequestState,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issues, err := s.retrieveGroupIssues(ListIssueParams{
+		UpdatedBefore: endDate,
+		UpdatedAfter:  startDate,
+		State:         issueState,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, release := range releases {
+		releases[i].MergeRequests = nil
+		releases[i].Issues = nil
+		for _, mr := range mrs {
+			if mr.ProjectID == release.Project.ID && release.inRange(mr.MergedAt) {
+				releases[i].MergeRequests = append(releases[i].MergeRequests, mr)
+			}
+		}
+		for _, iss := range issues {
+			if iss.ProjectID == release.Project.ID && release.inRange(iss.ClosedAt) {
+				releases[i].Issues = append(releases[i].Issues, iss)
+			}
+		}
+	}
+	return releases, nil
+}
+
+func (s *groupService) retrieveGroupMergeRequests(prs ListMReqParams) ([]MergeRequest, error) {
+	var pg Pagination
+	pg.SetDefaults()
+	var resp []MergeRequest
+	mrs, err := s.client.RetrieveGroupMergeRequests(prs, &pg)
+	if err != nil {
+		return nil, err
+	}
+	resp = append(resp, mrs...)
+
+	for pg.Page != GitLabDefaultPage {
+		mrs, err := s.client.RetrieveGroupMergeRequests(prs, &pg)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, mrs...)
+	}
+	return resp, nil
+}
+
+func (s *groupService) retrieveGroupIssues(prs ListIssueParams) ([]Issue, error) {
+	var pg Pagination
+	pg.SetDefaults()
+	var resp []Issue
+	issues, err := s.client.RetrieveGroupIssues(prs, &pg)
+	if err != nil {
+		return nil, err
+	}
+	resp = append(resp, issues...)
+
+	for pg.Page != GitLabDefaultPage {
+		issues, err := s.client.RetrieveGroupIssues(prs, &pg)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, issues...)
+	}
+	return resp, nil
+}
+
+type GitLabGroupClient interface {
+	RetrieveGroupProjects(pg *Pagination) ([]Repo, error)
+	RetrieveGroupMergeRequests(prs ListMReqParams, pg *Pagination) ([]MergeRequest, error)
+	RetrieveGroupIssues(prs ListIssueParams, pg *Pagination) ([]Issue, error)
+}
+
+// ProjectRelease is the release of a project within a group release.
+type ProjectRelease struct {
+	Project       Repo
+	LatestTag     Tag
+	PreviousTag   Tag
+	MergeRequests []MergeRequest
+	Issues        []Issue
+}
+
+func (r ProjectRelease) inRange(date time.Time) bool {
+	return date.After(r.PreviousTag.Commit.CommittedDate) && date.Before(r.LatestTag.Commit.CommittedDate)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGroupClient struct {
+	projects      []Repo
+	mergeRequests []MergeRequest
+	issues        []Issue
+	mrParams      []ListMReqParams
+}
+
+func (c *fakeGroupClient) RetrieveGroupProjects(pg *Pagination) ([]Repo, error) {
+	return page(c.projects, pg, 1), nil
+}
+
+func (c *fakeGroupClient) RetrieveGroupMergeRequests(prs ListMReqParams, pg *Pagination) ([]MergeRequest, error) {
+	c.mrParams = append(c.mrParams, prs)
+	return page(c.mergeRequests, pg, 2), nil
+}
+
+func (c *fakeGroupClient) RetrieveGroupIssues(prs ListIssueParams, pg *Pagination) ([]Issue, error) {
+	return page(c.issues, pg, 2), nil
+}
+
+func TestGroupService_RetrieveProjects(t *testing.T) {
+	client := &fakeGroupClient{projects: []Repo{{ID: 1}, {ID: 2}, {ID: 3}}}
+
+	projects, err := NewGroupService(client, Config{}).RetrieveProjects()
+	assert.NoError(t, err)
+	assert.Equal(t, []Repo{{ID: 1}, {ID: 2}, {ID: 3}}, projects)
+}
+
+func TestGroupService_RetrieveChangelogs(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	release := func(projectID, from, to int) ProjectRelease {
+		return ProjectRelease{
+			Project:     Repo{ID: projectID},
+			PreviousTag: Tag{Commit: Commit{CommittedDate: day(from)}},
+			LatestTag:   Tag{Commit: Commit{CommittedDate: day(to)}},
+		}
+	}
+	client := &fakeGroupClient{
+		mergeRequests: []MergeRequest{
+			{IID: 1, ProjectID: 1, MergedAt: day(3)},
+			{IID: 2, ProjectID: 1, MergedAt: day(12)},
+			{IID: 3, ProjectID: 2, MergedAt: day(12)},
+			{IID: 4, ProjectID: 2, MergedAt: day(3)},
+			{IID: 5, ProjectID: 3, MergedAt: day(3)},
+		},
+		issues: []Issue{
+			{IID: 6, ProjectID: 1, ClosedAt: day(4)},
+			{IID: 7, ProjectID: 2, ClosedAt: day(4)},
+		},
+	}
+
+	releases, err := NewGroupService(client, Config{TargetBranch: "main"}).RetrieveChangelogs([]ProjectRelease{
+		release(1, 1, 10),
+		release(2, 5, 15),
+	})
+	assert.NoError(t, err)
+
+	// The group endpoints are queried once over the union of the ranges.
+	assert.Equal(t, ListMReqParams{TargetBranch: "main", UpdatedAfter: day(1), UpdatedBefore: day(15), State: mergeRequestState}, client.mrParams[0])
+	assert.Len(t, client.mrParams, 3)
+
+	assert.Equal(t, []MergeRequest{{IID: 1, ProjectID: 1, MergedAt: day(3)}}, releases[0].MergeRequests)
+	assert.Equal(t, []Issue{{IID: 6, ProjectID: 1, ClosedAt: day(4)}}, releases[0].Issues)
+	assert.Equal(t, []MergeRequest{{IID: 3, ProjectID: 2, MergedAt: day(12)}}, releases[1].MergeRequests)
+	assert.Empty(t, releases[1].Issues)
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"time"
+)
+
+// HistoryEntry records a published release description, so that it can be
+// rolled back. The milestones are only recorded when the release got
+// milestones.
+type HistoryEntry struct {
+	RunID               string        `json:"run_id"`
+	ProjectID           string        `json:"project_id"`
+	Tag                 string        `json:"tag"`
+	Timestamp           time.Time     `json:"timestamp"`
+	Status              PublishStatus `json:"status"`
+	PreviousDescription string        `json:"previous_description"`
+	Description         string        `json:"description"`
+	PreviousMilestones  []string      `json:"previous_milestones,omitempty"`
+	Milestones          []string      `json:"milestones,omitempty"`
+	ToolVersion         string        `json:"tool_version"`
+}
+
+type HistoryStore interface {
+	Append(entry HistoryEntry) error
+	List() ([]HistoryEntry, error)
+}
+
+// EntriesOfRun returns the entries of a run, optionally limited to a tag.
+func EntriesOfRun(entries []HistoryEntry, runID, tag string) []HistoryEntry {
+	var result []HistoryEntry
+	for _, entry := range entries {
+		if entry.RunID == runID && (tag == "" || entry.Tag == tag) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ReleaseNote is the release note of a tag, independent of its output format.
+type ReleaseNote struct {
+	Tag         string
+	PreviousTag string
+	// Date is the commit date of the tag in the configured time zone.
+	Date time.Time
+	// URL is the web URL of the release, empty when the project URL is unknown.
+	URL string
+	// Summary lists the Markdown lines of the summary, without list markers.
+	Summary  []string
+	Sections []NoteSection
+	// Milestones are the milestones associated with the release.
+	Milestones []string
+}
+
+// NoteSection holds the entries of a label, Groups is only set when the
+// entries are grouped.
+type NoteSection struct {
+	Name    string
+	Title   string
+	Entries []Entry
+	Groups  []EntryGroup
+}
+
+// Markdown renders the release note the way it is published to GitLab.
+func (n ReleaseNote) Markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "### Release note (%s)\n", n.DateString())
+	if len(n.Summary) > 0 {
+		out.WriteString("#### Summary\n")
+		for _, line := range n.Summary {
+			out.WriteString("- " + line + "\n")
+		}
+	}
+
+	for _, section := range n.Sections {
+		fmt.Fprintf(&out, "#### %s\n", section.Title)
+		if len(section.Groups) == 0 {
+			out.WriteString(joinMessages(section.Entries))
+			continue
+		}
+
+		for _, group := range section.Groups {
+			fmt.Fprintf(&out, "##### %s\n", group.Title)
+			out.WriteString(joinMessages(group.Entries))
+		}
+	}
+	return out.String()
+}
+
+// DateString returns the release date formatted like in the note heading.
+func (n ReleaseNote) DateString() string {
+	return n.Date.Format(releaseNoteTimeFormat)
+}
+
+func releaseURL(projectURL, tagName string) string {
+	if projectURL == "" || tagName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/-/releases/%s", projectURL, url.PathEscape(tagName))
+}
+
+var markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// HTML renders the release note as an HTML fragment, the entries being lists
+// of links like in the Markdown note.
+func (n ReleaseNote) HTML() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "<h3>Release note (%s)</h3>\n", n.DateString())
+	if len(n.Summary) > 0 {
+		out.WriteString("<h4>Summary</h4>\n<ul>\n")
+		for _, line := range n.Summary {
+			out.WriteString("<li>" + inlineHTML(line) + "</li>\n")
+		}
+		out.WriteString("</ul>\n")
+	}
+
+	for _, section := range n.Sections {
+		fmt.Fprintf(&out, "<h4>%s</h4>\n", html.EscapeString(section.Title))
+		if len(section.Groups) == 0 {
+			out.WriteString(entriesHTML(section.Entries))
+			continue
+		}
+
+		for _, group := range section.Groups {
+			fmt.Fprintf(&out, "<h5>%s</h5>\n", html.EscapeString(group.Title))
+			out.WriteString(entriesHTML(group.Entries))
+		}
+	}
+	return out.String()
+}
+
+// entriesHTML renders the Markdown list items of entries, the commits of a
+// merge request being a nested list.
+func entriesHTML(entries []Entry) string {
+	var out strings.Builder
+	out.WriteString("<ul>\n")
+	for _, entry := range entries {
+		lines := strings.Split(entry.Message, "\n")
+		out.WriteString("<li>" + inlineHTML(strings.TrimPrefix(lines[0], "- ")))
+		if len(lines) > 1 {
+			out.WriteString("\n<ul>\n")
+			for _, line := range lines[1:] {
+				out.WriteString("<li>" + inlineHTML(strings.TrimPrefix(strings.TrimSpace(line), "- ")) + "</li>\n")
+			}
+			out.WriteString("</ul>\n")
+		}
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</ul>\n")
+	return out.String()
+}
+
+// inlineHTML escapes a line of Markdown and converts its http(s) links, the
+// other links are left as text.
+func inlineHTML(markdown string) string {
+	return markdownLinkRegex.ReplaceAllStringFunc(html.EscapeString(markdown), func(link string) string {
+		match := markdownLinkRegex.FindStringSubmatch(link)
+		u, err := url.Parse(html.UnescapeString(match[2]))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return link
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, match[2], match[1])
+	})
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineHTML(t *testing.T) {
+	tcs := []struct {
+		name     string
+		markdown string
+		html     string
+	}{
+		{"https link", "Fix login [#1](https://gitlab.example.com/mr/1)", `Fix login <a href="https://gitlab.example.com/mr/1">#1</a>`},
+		{"http link with query", "[page](http://example.com/?a=1&b=2)", `<a href="http://example.com/?a=1&amp;b=2">page</a>`},
+		{"escaped text", "<b>bold</b> & [x](https://example.com)", `&lt;b&gt;bold&lt;/b&gt; &amp; <a href="https://example.com">x</a>`},
+		{"javascript link", "[click](javascript:alert(1))", "[click](javascript:alert(1))"},
+		{"upper case scheme", "[click](JavaScript:alert(1))", "[click](JavaScript:alert(1))"},
+		{"data link", "[img](data:text/html;base64,PHNjcmlwdD4=)", "[img](data:text/html;base64,PHNjcmlwdD4=)"},
+		{"relative link", "[docs](/docs/index.md)", "[docs](/docs/index.md)"},
+		{"quote in url", `[x](https://example.com/"onmouseover=alert(1))`, `<a href="https://example.com/&#34;onmouseover=alert(1">x</a>)`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.html, inlineHTML(tc.markdown))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	// FailAtEnd publishes to every target and fails when any of them failed.
+	FailAtEnd = "fail_at_end"
+	// FailFast stops at the first failing target.
+	FailFast = "fail_fast"
+	// FailIgnore reports the failing targets without failing.
+	FailIgnore = "ignore"
+
+	gitLabPublisherName = "gitlab"
+	// PublishFailedCode is the code of the publishers failing.
+	PublishFailedCode = "publish_failed"
+)
+
+// Publisher outputs a release note to a target, eg: the GitLab release, a
+// file or a chat. A publisher failing after a write returns the status of the
+// write with the error, eg: a release updated before its links failed.
+type Publisher interface {
+	Name() string
+	Publish(note ReleaseNote) (PublishResult, error)
+}
+
+// Announcer is implemented by the publishers announcing releases to people,
+// they are skipped when a previous GitLab publisher reports the release
+// unchanged so that reruns don't announce it again.
+type Announcer interface {
+	Announces() bool
+}
+
+// PublisherResult is the outcome of a target.
+type PublisherResult struct {
+	Name   string
+	Result PublishResult
+	Err    error
+}
+
+func ValidateFailurePolicy(policy string) error {
+	switch policy {
+	case "", FailAtEnd, FailFast, FailIgnore:
+		return nil
+	default:
+		return errors.Errorf("Unsupported failure policy: %s", policy)
+	}
+}
+
+// PublishAll publishes the note to the publishers in order and returns the
+// result of each of them. The error aggregates the failures according to
+// the failure policy.
+func PublishAll(publishers []Publisher, note ReleaseNote, policy string) ([]PublisherResult, error) {
+	results := make([]PublisherResult, 0, len(publishers))
+	unchanged := false
+	var failures []string
+	for i, p := range publishers {
+		result := PublisherResult{Name: p.Name()}
+		if announcer, ok := p.(Announcer); ok && announcer.Announces() && unchanged {
+			result.Result.Status = PublishSkipped
+			results = append(results, result)
+			continue
+		}
+
+		result.Result, result.Err = p.Publish(note)
+		if result.Err != nil {
+			if result.Result.Status == "" {
+				result.Result.Status = PublishFailed
+			}
+			failures = append(failures, fmt.Sprintf("%s: %s", p.Name(), result.Err))
+		}
+		unchanged = unchanged || (p.Name() == gitLabPublisherName && result.Result.Status == PublishUnchanged)
+		results = append(results, result)
+
+		if result.Err != nil && policy == FailFast {
+			for _, skipped := range publishers[i+1:] {
+				results = append(results, PublisherResult{Name: skipped.Name(), Result: PublishResult{Status: PublishSkipped}})
+			}
+			break
+		}
+	}
+
+	if len(failures) == 0 || policy == FailIgnore {
+		return results, nil
+	}
+	err := errors.Errorf("%d of %d publishers failed: %s", len(failures), len(publishers), strings.Join(failures, "; "))
+	return results, errors.WithCode(err, PublishFailedCode)
+}
+
+// gitLabPublisher creates or updates the release of the tag.
+type gitLabPublisher struct {
+	svc GitLabService
+	tag Tag
+}
+
+// NewGitLabPublisher returns the publisher of the GitLab release of the tag,
+// the note is published as Markdown with its milestones.
+func NewGitLabPublisher(svc GitLabService, tag Tag) Publisher {
+	return &gitLabPublisher{svc, tag}
+}
+
+func (p *gitLabPublisher) Name() string {
+	return gitLabPublisherName
+}
+
+func (p *gitLabPublisher) Publish(note ReleaseNote) (PublishResult, error) {
+	return p.svc.Publish(p.tag, note.Markdown(), note.Milestones)
+}
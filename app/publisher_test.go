@@ -0,0 +1,99 @@
+package app
+
+import (
+	"testing"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePublisher struct {
+	name      string
+	status    PublishStatus
+	err       error
+	announces bool
+	published int
+}
+
+func (p *fakePublisher) Name() string { return p.name }
+
+func (p *fakePublisher) Publish(note ReleaseNote) (PublishResult, error) {
+	p.published++
+	return PublishResult{Status: p.status}, p.err
+}
+
+type fakeAnnouncer struct{ fakePublisher }
+
+func (p *fakeAnnouncer) Announces() bool { return true }
+
+func statuses(results []PublisherResult) []PublishStatus {
+	var s []PublishStatus
+	for _, result := range results {
+		s = append(s, result.Result.Status)
+	}
+	return s
+}
+
+func TestPublishAll_FailurePolicy(t *testing.T) {
+	tcs := []struct {
+		policy   string
+		statuses []PublishStatus
+		failed   bool
+	}{
+		{FailAtEnd, []PublishStatus{PublishCreated, PublishFailed, PublishSent}, true},
+		{FailFast, []PublishStatus{PublishCreated, PublishFailed, PublishSkipped}, true},
+		{FailIgnore, []PublishStatus{PublishCreated, PublishFailed, PublishSent}, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.policy, func(t *testing.T) {
+			publishers := []Publisher{
+				&fakePublisher{name: "gitlab", status: PublishCreated},
+				&fakePublisher{name: "file", err: errors.New("disk full")},
+				&fakeAnnouncer{fakePublisher{name: "slack", status: PublishSent}},
+			}
+
+			results, err := PublishAll(publishers, ReleaseNote{}, tc.policy)
+			assert.Equal(t, tc.statuses, statuses(results))
+			assert.Equal(t, tc.failed, err != nil)
+			if tc.failed {
+				assert.Equal(t, "1 of 3 publishers failed: file: disk full", err.Error())
+				assert.Equal(t, PublishFailedCode, errors.ErrorCode(err))
+			}
+		})
+	}
+}
+
+func TestPublishAll_SkipsAnnouncersOfUnchangedRelease(t *testing.T) {
+	slack := &fakeAnnouncer{fakePublisher{name: "slack", status: PublishSent}}
+	file := &fakePublisher{name: "file", status: PublishUpdated}
+	publishers := []Publisher{&fakePublisher{name: "gitlab", status: PublishUnchanged}, slack, file}
+
+	results, err := PublishAll(publishers, ReleaseNote{}, FailAtEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, []PublishStatus{PublishUnchanged, PublishSkipped, PublishUpdated}, statuses(results))
+	assert.Equal(t, 0, slack.published)
+	assert.Equal(t, 1, file.published)
+}
+
+func TestPublishAll_AnnouncesAfterUnchangedFile(t *testing.T) {
+	slack := &fakeAnnouncer{fakePublisher{name: "slack", status: PublishSent}}
+	publishers := []Publisher{&fakePublisher{name: "file", status: PublishUnchanged}, &fakePublisher{name: "gitlab", status: PublishUpdated}, slack}
+
+	results, err := PublishAll(publishers, ReleaseNote{}, FailAtEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, []PublishStatus{PublishUnchanged, PublishUpdated, PublishSent}, statuses(results))
+	assert.Equal(t, 1, slack.published)
+}
+
+func TestPublishAll_KeepsStatusOfFailedWrite(t *testing.T) {
+	publishers := []Publisher{
+		&fakePublisher{name: "gitlab", status: PublishUpdated, err: errors.New("links failed")},
+		&fakePublisher{name: "file", err: errors.New("disk full")},
+	}
+
+	results, err := PublishAll(publishers, ReleaseNote{}, FailAtEnd)
+	assert.Error(t, err)
+	assert.Equal(t, []PublishStatus{PublishUpdated, PublishFailed}, statuses(results))
+}
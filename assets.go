@@ -0,0 +1,49 @@
+package main
+
+import (
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/spf13/viper"
+)
+
+// loadAssetLinks reads the release asset links of the config files and CI
+// manifests, a YAML/JSON/TOML file listing them under "links":
+//
+//	links:
+//	  - name: linux-amd64
+//	    url: https://example.com/app-linux-amd64
+//	    link_type: package
+//	    direct_asset_path: /binaries/app-linux-amd64
+//
+// A link defined in several files keeps the last definition.
+func loadAssetLinks(files []string) ([]app.ReleaseLink, error) {
+	var links []app.ReleaseLink
+	indexByName := make(map[string]int)
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		var fileLinks []app.ReleaseLink
+		if err := v.UnmarshalKey("links", &fileLinks); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		for _, link := range fileLinks {
+			if err := link.Validate(); err != nil {
+				return nil, errors.WithMessage(err, file)
+			}
+
+			if i, exists := indexByName[link.Name]; exists {
+				links[i] = link
+				continue
+			}
+			indexByName[link.Name] = len(links)
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+// runBackfill publishes the release notes of the tags matching
+// TARGET_TAG_REGEX on the target branch, oldest first and without announcing
+// them. The tags are published since the since tag, or the limit latest ones.
+// The changes of a tag are the ones since the listed tag preceding it, of the
+// same package in monorepo mode, so that the tags are not listed again for
+// each of them.
+func runBackfill(env envConfig, opts runOptions, since string, limit int, dryRun bool) error {
+	regex, err := regexp.Compile(env.TargetTagRegex)
+	if err != nil {
+		return configError(err)
+	}
+	tags, err := backfillTags(env, regex, since, limit)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return errors.WithNotFound(errors.New("No tag matching TARGET_TAG_REGEX to backfill."), app.TagNotFoundCode)
+	}
+
+	if dryRun {
+		for _, tag := range tags {
+			fmt.Println(tag)
+		}
+		return nil
+	}
+
+	opts.skipAnnouncements = true
+	failed := backfillReleases(env, opts, regex, tags, runRecovered)
+	fmt.Printf("Run %s\n", opts.runID)
+	if failed > 0 {
+		return errors.Errorf("%d of %d releases failed to publish.", failed, len(tags))
+	}
+	return nil
+}
+
+// backfillTags lists the names of the tags to backfill, oldest first. Only
+// the tags on the target branch are listed, and in monorepo mode the tags of
+// TARGET_PACKAGE when it is set.
+func backfillTags(env envConfig, regex *regexp.Regexp, since string, limit int) ([]string, error) {
+	client := newGitLabClient(env)
+	gitLabSvc := newGitLabService(env, client, nil)
+	var pg app.Pagination
+	pg.SetDefaults()
+	var names []string
+	found := false
+	for !found && (limit <= 0 || len(names) < limit) {
+		tags, err := client.RetrieveTags(&pg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			if !regex.MatchString(tag.Name) {
+				continue
+			}
+			if env.Monorepo && env.TargetPackage != "" && app.PackageOfTag(regex, tag.Name) != env.TargetPackage {
+				continue
+			}
+			found = tag.Name == since
+			// Like in serve mode, the tags off the target branch are
+			// skipped. The since tag still ends the list.
+			inTargetBranch, err := gitLabSvc.IsTagInTargetBranch(tag.Name)
+			if err != nil {
+				return nil, err
+			}
+			if inTargetBranch {
+				names = append(names, tag.Name)
+			}
+			if found || (limit > 0 && len(names) == limit) {
+				break
+			}
+		}
+
+		if pg.Page == app.GitLabDefaultPage {
+			break
+		}
+	}
+	if since != "" && !found {
+		return nil, errors.WithNotFound(errors.Errorf("Cannot find tag %q.", since), app.TagNotFoundCode)
+	}
+
+	// The tags are listed newest first.
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names, nil
+}
+
+// backfillReleases publishes the releases of the tags, oldest first, with
+// runTag and returns the number of failed releases.
+func backfillReleases(env envConfig, opts runOptions, regex *regexp.Regexp, tags []string, runTag func(envConfig, runOptions) error) int {
+	failed := 0
+	// previous holds the last listed tag of each package, the oldest tag
+	// falls back to the lookup of the tag preceding it.
+	previous := make(map[string]string)
+	for _, tag := range tags {
+		var pkg string
+		if env.Monorepo {
+			pkg = app.PackageOfTag(regex, tag)
+		}
+		tagOpts := opts
+		tagOpts.tag, tagOpts.previousTag = tag, previous[pkg]
+		previous[pkg] = tag
+		if err := runTag(env, tagOpts); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Cannot publish release %s: %s\n", tag, err)
+		}
+	}
+	return failed
+}
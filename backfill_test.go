@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tagResponses returns the responses of the tags and of the branches of
+// their commits.
+func tagResponses(branches map[string]string) map[string]string {
+	responses := make(map[string]string)
+	for tag, branch := range branches {
+		responses["GET /projects/42/repository/tags/"+tag] = fmt.Sprintf(`{"name":%q,"commit":{"id":"%s-sha"}}`, tag, tag)
+		responses["GET /projects/42/repository/commits/"+tag+"-sha/refs"] = fmt.Sprintf(`[{"name":%q}]`, branch)
+	}
+	return responses
+}
+
+func TestBackfillTags(t *testing.T) {
+	responses := tagResponses(map[string]string{"v1.2.0": "main", "v1.1.1": "hotfix", "v1.1.0": "main", "v1.0.0": "main"})
+	responses["GET /projects/42/repository/tags"] = `[{"name":"v1.2.0"},{"name":"nightly"},{"name":"v1.1.1"},{"name":"v1.1.0"},{"name":"v1.0.0"}]`
+	_, endpoint := newFakeGitLab(t, responses)
+	env := testEnv(endpoint)
+	regex := regexp.MustCompile(env.TargetTagRegex)
+
+	tcs := []struct {
+		name  string
+		since string
+		limit int
+		tags  []string
+	}{
+		{"every tag", "", 0, []string{"v1.0.0", "v1.1.0", "v1.2.0"}},
+		{"since", "v1.1.0", 0, []string{"v1.1.0", "v1.2.0"}},
+		{"since off the branch", "v1.1.1", 0, []string{"v1.2.0"}},
+		{"limit", "", 2, []string{"v1.1.0", "v1.2.0"}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tags, err := backfillTags(env, regex, tc.since, tc.limit)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.tags, tags)
+		})
+	}
+
+	_, err := backfillTags(env, regex, "v0.9.0", 0)
+	assert.Equal(t, exitNotFound, exitCode(err))
+}
+
+func TestBackfillTags_TargetPackage(t *testing.T) {
+	responses := tagResponses(map[string]string{"api-v1.1.0": "main", "web-v1.0.0": "main", "api-v1.0.0": "main"})
+	responses["GET /projects/42/repository/tags"] = `[{"name":"api-v1.1.0"},{"name":"web-v1.0.0"},{"name":"api-v1.0.0"}]`
+	_, endpoint := newFakeGitLab(t, responses)
+	env := testEnv(endpoint)
+	env.Monorepo, env.TargetTagRegex = true, `^(\w+)-v.*$`
+	regex := regexp.MustCompile(env.TargetTagRegex)
+
+	tags, err := backfillTags(env, regex, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api-v1.0.0", "web-v1.0.0", "api-v1.1.0"}, tags)
+
+	env.TargetPackage = "api"
+	tags, err = backfillTags(env, regex, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api-v1.0.0", "api-v1.1.0"}, tags)
+}
+
+func TestBackfillReleases(t *testing.T) {
+	env := testEnv("")
+	env.Monorepo = true
+	regex := regexp.MustCompile(`^(\w+)-v.*$`)
+	tags := []string{"api-v1.0.0", "web-v1.0.0", "api-v1.1.0", "api-v1.2.0", "web-v1.1.0"}
+
+	previous := make(map[string]string)
+	failed := backfillReleases(env, runOptions{runID: "run-1"}, regex, tags, func(env envConfig, opts runOptions) error {
+		assert.Equal(t, "run-1", opts.runID)
+		previous[opts.tag] = opts.previousTag
+		if opts.tag == "api-v1.1.0" {
+			return errors.New("Cannot publish.")
+		}
+		return nil
+	})
+
+	assert.Equal(t, 1, failed)
+	// The previous tag of a failed release is still the listed one.
+	assert.Equal(t, map[string]string{
+		"api-v1.0.0": "",
+		"web-v1.0.0": "",
+		"api-v1.1.0": "api-v1.0.0",
+		"api-v1.2.0": "api-v1.1.0",
+		"web-v1.1.0": "web-v1.0.0",
+	}, previous)
+
+	env.Monorepo = false
+	previous = make(map[string]string)
+	backfillReleases(env, runOptions{}, regex, tags[:3], func(env envConfig, opts runOptions) error {
+		previous[opts.tag] = opts.previousTag
+		return nil
+	})
+	assert.Equal(t, map[string]string{"api-v1.0.0": "", "web-v1.0.0": "api-v1.0.0", "api-v1.1.0": "web-v1.0.0"}, previous)
+}
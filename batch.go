@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gitLab-rls-note/pkg/config"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	batchCommand       = "batch"
+	defaultBatchWorker = 4
+)
+
+// batchConfig lists the projects of a batch run, every project inherits the
+// env config and overrides parts of it.
+type batchConfig struct {
+	Workers  int            `mapstructure:"workers"`
+	Projects []batchProject `mapstructure:"projects"`
+}
+
+type batchProject struct {
+	// ID is the project id or path.
+	ID             string `mapstructure:"id"`
+	TargetBranch   string `mapstructure:"target_branch"`
+	TargetTagRegex string `mapstructure:"tag_regex"`
+	// Overrides are env config values keyed by env name, eg: GROUP_BY.
+	Overrides map[string]string `mapstructure:"overrides"`
+}
+
+type batchResult struct {
+	projectID string
+	duration  time.Duration
+	err       error
+}
+
+// runBatch processes the projects of the batch config file concurrently and
+// prints a status report. It fails when any project failed.
+func runBatch(env envConfig, opts runOptions, args []string) error {
+	file := os.Getenv("BATCH_CONFIG_FILE")
+	if len(args) > 0 {
+		file = args[0]
+	}
+
+	cfg, err := loadBatchConfig(file)
+	if err != nil {
+		return configError(errors.WithMessage(err, "Cannot load batch config"))
+	}
+
+	results := runBatchProjects(cfg, env, opts, run)
+	fmt.Printf("Run %s\n", opts.runID)
+	if failed := printBatchReport(results); failed > 0 {
+		return errors.Errorf("%d of %d projects failed.", failed, len(results))
+	}
+	return nil
+}
+
+// runBatchProjects runs the projects with the workers of the config and
+// returns their results in the config order.
+func runBatchProjects(cfg batchConfig, env envConfig, opts runOptions, runProject func(envConfig, runOptions) error) []batchResult {
+	results := make([]batchResult, len(cfg.Projects))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runBatchProject(env, opts, cfg.Projects[i], runProject)
+			}
+		}()
+	}
+
+	for i := range cfg.Projects {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func loadBatchConfig(file string) (batchConfig, error) {
+	if file == "" {
+		return batchConfig{}, errors.New("Missing batch config file.")
+	}
+
+	v := viper.New()
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return batchConfig{}, errors.WithStack(err)
+	}
+
+	var cfg batchConfig
+	if err := v.Unmarshal(&cfg); err != nil {
+		return batchConfig{}, errors.WithStack(err)
+	}
+
+	for i, project := range cfg.Projects {
+		if project.ID == "" {
+			return batchConfig{}, errors.Errorf("Missing id of project #%d.", i+1)
+		}
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = defaultBatchWorker
+	}
+	return cfg, nil
+}
+
+// runBatchProject runs a single project, a failing project never stops the others.
+func runBatchProject(env envConfig, opts runOptions, project batchProject, runProject func(envConfig, runOptions) error) (result batchResult) {
+	start := time.Now()
+	result.projectID = project.ID
+	defer func() {
+		result.duration = time.Since(start)
+	}()
+
+	env.ProjectID = project.ID
+	if project.TargetBranch != "" {
+		env.TargetBranch = project.TargetBranch
+	}
+	if project.TargetTagRegex != "" {
+		env.TargetTagRegex = project.TargetTagRegex
+	}
+	if err := config.OverrideConfig(&env, project.Overrides); err != nil {
+		result.err = err
+		return
+	}
+
+	result.err = recovered(runProject, env, opts)
+	return
+}
+
+// runRecovered runs like run, turning panics into errors so that the other
+// projects of a long-lived process go on.
+func runRecovered(env envConfig, opts runOptions) error {
+	return recovered(run, env, opts)
+}
+
+func recovered(runProject func(envConfig, runOptions) error, env envConfig, opts runOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("panic: %v", r)
+		}
+	}()
+	return runProject(env, opts)
+}
+
+// printBatchReport prints the status of every project and returns the number
+// of failed projects.
+func printBatchReport(results []batchResult) int {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tSTATUS\tDURATION\tERROR")
+	for _, result := range results {
+		status, message := "ok", ""
+		if result.err != nil {
+			status, message = "failed", result.err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.projectID, status, result.duration.Round(time.Millisecond), message)
+	}
+	w.Flush()
+	return failed
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	return file
+}
+
+func TestLoadBatchConfig(t *testing.T) {
+	file := writeFile(t, "batch.yaml", `
+projects:
+  - id: mygroup/api
+    target_branch: develop
+    tag_regex: ^api-.*$
+    overrides:
+      GROUP_BY: label
+  - id: "42"
+`)
+
+	cfg, err := loadBatchConfig(file)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultBatchWorker, cfg.Workers)
+	assert.Equal(t, []batchProject{
+		{ID: "mygroup/api", TargetBranch: "develop", TargetTagRegex: "^api-.*$", Overrides: map[string]string{"group_by": "label"}},
+		{ID: "42"},
+	}, cfg.Projects)
+}
+
+func TestLoadBatchConfig_Invalid(t *testing.T) {
+	_, err := loadBatchConfig("")
+	assert.EqualError(t, err, "Missing batch config file.")
+
+	_, err = loadBatchConfig(writeFile(t, "batch.yaml", "workers: 2\nprojects:\n  - target_branch: main\n"))
+	assert.EqualError(t, err, "Missing id of project #1.")
+}
+
+func TestRunBatch_InvalidConfig(t *testing.T) {
+	for _, file := range []string{
+		filepath.Join(t.TempDir(), "missing.yaml"),
+		writeFile(t, "batch.yaml", "projects:\n  - target_branch: main\n"),
+	} {
+		err := runBatch(envConfig{}, runOptions{}, []string{file})
+		assert.Equal(t, exitConfig, exitCode(err))
+		assert.Contains(t, err.Error(), "Cannot load batch config: ")
+	}
+}
+
+func TestRunBatchProjects(t *testing.T) {
+	cfg := batchConfig{Workers: 2, Projects: []batchProject{
+		{ID: "mygroup/api", TargetBranch: "develop", Overrides: map[string]string{"group_by": "label", "INCLUDE_COMMITS": "true"}},
+		{ID: "mygroup/panics"},
+		{ID: "mygroup/fails", TargetTagRegex: "^v.*$"},
+		{ID: "mygroup/invalid", Overrides: map[string]string{"INCLUDE_COMMITS": "maybe"}},
+	}}
+	env := envConfig{TargetBranch: "main", TargetTagRegex: ".*", GroupBy: "path"}
+
+	var mu sync.Mutex
+	envs := make(map[string]envConfig)
+	results := runBatchProjects(cfg, env, runOptions{runID: "run-1"}, func(env envConfig, opts runOptions) error {
+		mu.Lock()
+		envs[env.ProjectID] = env
+		mu.Unlock()
+
+		assert.Equal(t, "run-1", opts.runID)
+		switch env.ProjectID {
+		case "mygroup/panics":
+			panic("boom")
+		case "mygroup/fails":
+			return errors.New("Cannot find tag.")
+		}
+		return nil
+	})
+
+	assert.Len(t, results, 4)
+	assert.Equal(t, "mygroup/api", results[0].projectID)
+	assert.NoError(t, results[0].err)
+	assert.EqualError(t, results[1].err, "panic: boom")
+	assert.EqualError(t, results[2].err, "Cannot find tag.")
+	assert.ErrorContains(t, results[3].err, "Invalid bool of INCLUDE_COMMITS")
+
+	api := envs["mygroup/api"]
+	assert.Equal(t, "develop", api.TargetBranch)
+	assert.Equal(t, ".*", api.TargetTagRegex)
+	assert.Equal(t, "label", api.GroupBy)
+	assert.True(t, api.IncludeCommits)
+
+	fails := envs["mygroup/fails"]
+	assert.Equal(t, "main", fails.TargetBranch)
+	assert.Equal(t, "^v.*$", fails.TargetTagRegex)
+	assert.Equal(t, "path", fails.GroupBy)
+	assert.NotContains(t, envs, "mygroup/invalid")
+}
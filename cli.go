@@ -0,0 +1,302 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gitLab-rls-note/pkg/config"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/pkg/logging"
+	"gitLab-rls-note/publisher"
+	"gitLab-rls-note/store"
+)
+
+const (
+	programName = "rlsnote"
+
+	generateCommand       = "generate"
+	previewCommand        = "preview"
+	publishCommand        = "publish"
+	backfillCommand       = "backfill"
+	validateConfigCommand = "validate-config"
+
+	exitOK = 0
+	// exitFailure is the exit code of a failed command, or of a release
+	// description differing with --fail-on-diff.
+	exitFailure = 1
+	// exitUsage is the exit code of an unknown command or invalid flags.
+	exitUsage = 2
+)
+
+// invalidArgumentsCode is the code of an action called with invalid arguments.
+const invalidArgumentsCode = "invalid_arguments"
+
+// command is a subcommand of the CLI, every command also takes the env
+// options as flags.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// setup adds the flags of the command and returns its action, called
+	// with the env config overridden by the flags and the other arguments.
+	setup func(fs *flag.FlagSet) func(env envConfig, args []string) error
+}
+
+var commands = []command{
+	{
+		name:    generateCommand,
+		summary: "Print the release note of the latest tag, or of --tag, without publishing it",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			tag := fs.String("tag", "", "Generate the release note of this tag instead of the latest one")
+			from := fs.String("from", "", "Generate the changes since this tag instead of the previous one, requires --tag")
+			format := fs.String("format", publisher.FormatMarkdown, "The format of the release note: markdown, html or json")
+			return func(env envConfig, args []string) error {
+				if *from != "" && *tag == "" {
+					return errors.WithInvalid(errors.New("--from requires --tag."), invalidArgumentsCode)
+				}
+				if err := publisher.ValidateFormat(*format); err != nil {
+					return errors.WithInvalid(err, invalidArgumentsCode)
+				}
+
+				g, err := generateReleaseNote(env, *tag, *from, *format == publisher.FormatJSON)
+				if err != nil {
+					return err
+				}
+				body, err := publisher.Render(g.note, *format)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(body)
+				return errors.WithStack(err)
+			}
+		},
+	},
+	{
+		name:    previewCommand,
+		summary: "Print the diff of the release description without publishing it",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			opts := runOptions{diff: true}
+			fs.StringVar(&opts.tag, "tag", "", "Preview the release of this tag instead of the latest one")
+			fs.BoolVar(&opts.failOnDiff, "fail-on-diff", false, "Exit with 1 when the release description differs")
+			return func(env envConfig, args []string) error {
+				return run(env, withHistory(env, opts))
+			}
+		},
+	},
+	{
+		name:    publishCommand,
+		summary: "Generate and publish the release note, the default command",
+		setup:   setupPublish,
+	},
+	{
+		name:    backfillCommand,
+		summary: "Publish the release notes of the past tags, oldest first, without the announcements",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			since := fs.String("since", "", "The oldest tag to publish, every matching tag when empty")
+			limit := fs.Int("limit", 0, "Only publish the latest tags, every tag when 0")
+			dryRun := fs.Bool("dry-run", false, "Only list the tags to publish")
+			return func(env envConfig, args []string) error {
+				return runBackfill(env, withHistory(env, runOptions{}), *since, *limit, *dryRun)
+			}
+		},
+	},
+	{
+		name:    validateConfigCommand,
+		summary: "Check the options, the config files and the project path",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			return func(env envConfig, args []string) error {
+				if err := validateConfig(env); err != nil {
+					return configError(err)
+				}
+				fmt.Println("Config is valid.")
+				return nil
+			}
+		},
+	},
+	{
+		name:    serveCommand,
+		summary: "Release the tags pushed to GitLab and serve the notes API",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			return func(env envConfig, args []string) error {
+				return runServe(env, withHistory(env, runOptions{}))
+			}
+		},
+	},
+	{
+		name:    batchCommand,
+		args:    "[batch config file]",
+		summary: "Publish the release notes of the projects of a batch config file",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			return func(env envConfig, args []string) error {
+				return runBatch(env, withHistory(env, runOptions{}), args)
+			}
+		},
+	},
+	{
+		name:    groupCommand,
+		summary: "Print the release note combining the projects of a group",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			return func(env envConfig, args []string) error {
+				content, err := runGroup(env)
+				if err != nil {
+					return err
+				}
+				fmt.Print(content)
+				return nil
+			}
+		},
+	},
+	{
+		name:    rollbackCommand,
+		summary: "Restore the releases published by a run, or list the runs",
+		setup: func(fs *flag.FlagSet) func(envConfig, []string) error {
+			runID := fs.String("run-id", "", "The run to roll back, the runs are listed when empty")
+			tag := fs.String("tag", "", "Only roll back the release of this tag")
+			return func(env envConfig, args []string) error {
+				return runRollback(env, withHistory(env, runOptions{}).history, *runID, *tag)
+			}
+		},
+	},
+}
+
+func setupPublish(fs *flag.FlagSet) func(envConfig, []string) error {
+	var opts runOptions
+	fs.StringVar(&opts.tag, "tag", "", "Publish the release of this tag instead of the latest one")
+	fs.BoolVar(&opts.diff, "diff", false, "Print the diff of the release description without publishing")
+	fs.BoolVar(&opts.confirm, "confirm", false, "Print the diff of the release description and ask before publishing")
+	fs.BoolVar(&opts.failOnDiff, "fail-on-diff", false, "Print the diff of the release description and exit with 1 when there is any, without publishing")
+	return func(env envConfig, args []string) error {
+		return run(env, withHistory(env, opts))
+	}
+}
+
+// withHistory sets the history and a new run id of the options.
+func withHistory(env envConfig, opts runOptions) runOptions {
+	if env.HistoryFile == "" {
+		env.HistoryFile = defaultHistoryFile
+	}
+	opts.history = store.NewHistoryStore(env.HistoryFile)
+	opts.runID = newRunID()
+	return opts
+}
+
+// runCLI runs the command of the arguments and returns the exit code. The
+// publish command runs without a command name, for the flags of the
+// previous versions.
+func runCLI(args []string, stderr io.Writer) int {
+	name := publishCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && isHelp(args[0]) {
+		printUsage(stderr)
+		return exitOK
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command %q.\n\n", name)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	var env envConfig
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	action := cmd.setup(fs)
+	verbose := fs.Bool("verbose", false, "Report the stack trace of the errors")
+	errorFormat := fs.String("error-format", errorFormatText, "The format of the errors: text or json")
+	addOptionFlags(fs, &env)
+	fs.Usage = func() { printCommandUsage(stderr, cmd, fs) }
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if *errorFormat != errorFormatText && *errorFormat != errorFormatJSON {
+		fmt.Fprintf(stderr, "Unsupported error format: %s\n", *errorFormat)
+		fs.Usage()
+		return exitUsage
+	}
+
+	envFileErr := config.LoadEnvConfig()
+	if err := config.UnmarshalEnvConfig(&env); err != nil {
+		return reportError(stderr, configError(err), *errorFormat, *verbose)
+	}
+	if err := overrideOptions(fs, &env); err != nil {
+		return reportError(stderr, errors.WithInvalid(err, invalidArgumentsCode), *errorFormat, *verbose)
+	}
+	logger, err := logging.New(stderr, env.LogFormat, env.LogLevel)
+	if err != nil {
+		return reportError(stderr, configError(err), *errorFormat, *verbose)
+	}
+	slog.SetDefault(logger)
+	// The .env file is optional.
+	if errors.Is(envFileErr, os.ErrNotExist) {
+		slog.Debug("Not loading env from file", "error", envFileErr)
+	} else if envFileErr != nil {
+		slog.Warn("Not loading env from file", "error", envFileErr)
+	}
+
+	err = runAction(action, env, fs.Args())
+	if err == nil {
+		return exitOK
+	}
+	exit := reportError(stderr, err, *errorFormat, *verbose)
+	if exit == exitUsage && *errorFormat == errorFormatText {
+		fs.Usage()
+	}
+	return exit
+}
+
+// runAction runs the action of a command, a panic is returned as an error.
+func runAction(action func(envConfig, []string) error, env envConfig, args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.WithCode(errors.Errorf("panic: %v", r), internalErrorCode)
+		}
+	}()
+	return action(env, args)
+}
+
+func isHelp(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [options]\n\nCommands:\n", programName)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s%s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> --help' for the flags and options of a command. The options\noverride the environment variables and the .env file.\n", programName)
+}
+
+func printCommandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	usage := fmt.Sprintf("Usage: %s %s [flags] [options]", programName, cmd.name)
+	if cmd.args != "" {
+		usage += " " + cmd.args
+	}
+	fmt.Fprintf(w, "%s\n\n%s.\n", usage, cmd.summary)
+
+	hasFlags := false
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := f.Value.(*optionValue); !ok {
+			hasFlags = true
+		}
+	})
+	if hasFlags {
+		fmt.Fprintln(w, "\nFlags:")
+		printFlags(w, fs, false)
+	}
+	fmt.Fprintln(w, "\nOptions, overriding the environment variables:")
+	printFlags(w, fs, true)
+}
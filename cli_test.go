@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// setEnv sets the env options read by runCLI.
+func setEnv(t *testing.T, values map[string]string) {
+	viper.AutomaticEnv()
+	for name, value := range values {
+		t.Setenv(name, value)
+	}
+}
+
+func TestRunCLI_Help(t *testing.T) {
+	var stderr bytes.Buffer
+	assert.Equal(t, exitOK, runCLI([]string{"--help"}, &stderr))
+	assert.Contains(t, stderr.String(), "Usage: rlsnote <command> [flags] [options]")
+	for _, cmd := range commands {
+		assert.Contains(t, stderr.String(), "  "+cmd.name)
+	}
+
+	stderr.Reset()
+	assert.Equal(t, exitOK, runCLI([]string{"generate", "--help"}, &stderr))
+	assert.Contains(t, stderr.String(), "Usage: rlsnote generate [flags] [options]")
+	assert.Contains(t, stderr.String(), "\nFlags:\n  --error-format string")
+	assert.Contains(t, stderr.String(), "  --format string\n    \tThe format of the release note: markdown, html or json (default markdown)\n")
+	assert.Contains(t, stderr.String(), "  --target-branch string\n    \tThe branch to look for release tags, eg: main [$TARGET_BRANCH]")
+
+	stderr.Reset()
+	assert.Equal(t, exitOK, runCLI([]string{"batch", "-h"}, &stderr))
+	assert.Contains(t, stderr.String(), "Usage: rlsnote batch [flags] [options] [batch config file]")
+}
+
+func TestRunCLI(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/repository/tags/v1.1.0": `{"name":"v1.1.0","commit":{"id":"b1","committed_date":"2024-05-10T00:00:00Z"}}`,
+		"GET /projects/42/repository/tags/v1.0.0": `{"name":"v1.0.0","commit":{"id":"a1","committed_date":"2024-05-01T00:00:00Z"}}`,
+		"GET /projects/42/merge_requests":         `[]`,
+		"GET /projects/42/issues":                 `[]`,
+	})
+	setEnv(t, map[string]string{
+		"GITLAB_PERSONAL_TOKEN": "token",
+		"GITLAB_API_ENDPOINT":   endpoint,
+		"GITLAB_PROJECT_ID":     "7",
+		"TARGET_BRANCH":         "main",
+		"TARGET_TAG_REGEX":      "^v.*$",
+		"TZ":                    "UTC",
+		"GROUP_BY":              "",
+	})
+
+	tcs := []struct {
+		name   string
+		args   []string
+		exit   int
+		stderr string
+	}{
+		{"validate", []string{"validate-config"}, exitOK, ""},
+		{"validate any branch", []string{"validate-config", "--target-branch", ""}, exitOK, ""},
+		{"unknown command", []string{"release"}, exitUsage, "Unknown command \"release\".\n\nUsage: rlsnote <command>"},
+		{"unknown flag", []string{"generate", "--nope"}, exitUsage, "flag provided but not defined: -nope"},
+		{"flag of another command", []string{"validate-config", "--tag", "v1.1.0"}, exitUsage, "flag provided but not defined: -tag"},
+		{"invalid error format", []string{"generate", "--error-format", "xml"}, exitUsage, "Unsupported error format: xml"},
+		{"invalid option", []string{"validate-config", "--include-commits=maybe"}, exitUsage, "Invalid bool of INCLUDE_COMMITS"},
+		{"option overriding env", []string{"validate-config", "--group-by", "size"}, exitConfig, "Error: Unsupported group by: size"},
+		{"invalid flags", []string{"generate", "--from", "v1.0.0"}, exitUsage, "Error: --from requires --tag.\nUsage: rlsnote generate"},
+		{"project of the env", []string{"generate", "--tag", "v1.1.0", "--from", "v1.0.0"}, exitNotFound, "Error: Cannot find tag \"v1.1.0\"."},
+		{"project of the option", []string{"generate", "--tag", "v1.1.0", "--from", "v1.0.0", "--gitlab-project-id", "42"}, exitOK, ""},
+		{"unknown tag", []string{"generate", "--tag", "v9.0.0", "--from", "v1.0.0", "--gitlab-project-id", "42"}, exitNotFound, "Error: Cannot find tag \"v9.0.0\"."},
+		{"default command", []string{"--gitlab-project-id", "42", "--publish-failure-policy", "retry"}, exitConfig, "Error: Unsupported failure policy: retry"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			assert.Equal(t, tc.exit, runCLI(tc.args, &stderr), stderr.String())
+			if tc.stderr != "" {
+				assert.Contains(t, stderr.String(), tc.stderr)
+			}
+		})
+	}
+}
+
+func TestRunCLI_ErrorFormat(t *testing.T) {
+	setEnv(t, map[string]string{"GITLAB_PERSONAL_TOKEN": "", "TARGET_BRANCH": ""})
+
+	var stderr bytes.Buffer
+	assert.Equal(t, exitConfig, runCLI([]string{"validate-config", "--error-format", "json"}, &stderr))
+
+	var report errorReport
+	assert.NoError(t, json.Unmarshal(stderr.Bytes(), &report))
+	assert.Equal(t, invalidConfigCode, report.Error.Code)
+	assert.Equal(t, exitConfig, report.Error.ExitCode)
+	assert.Contains(t, report.Error.Message, "Missing ")
+	// An empty TARGET_BRANCH is any branch.
+	assert.NotContains(t, report.Error.Message, "TARGET_BRANCH")
+	assert.Empty(t, report.Error.Details)
+}
@@ -1,6 +1,6 @@
 module gitLab-rls-note
 
-go 1.19
+go 1.21
 
 require (
 	github.com/spf13/viper v1.17.0
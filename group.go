@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/store"
+)
+
+const groupCommand = "group"
+
+// runGroup generates one release note combining the releases of every project
+// of the group, each project being released at its product tag.
+func runGroup(env envConfig) (string, error) {
+	if env.GroupID == "" {
+		return "", configError(errors.New("Missing GITLAB_GROUP_ID."))
+	}
+
+	componentRules, err := loadComponentRules(env)
+	if err != nil {
+		return "", configError(err)
+	}
+
+	groupClient := store.NewGitlabGroupClient(env.PersonalToken, env.APIEndpoint, env.GroupID, env.ZeroTrustCookie)
+	groupSvc := app.NewGroupService(groupClient, newGitLabConfig(env, componentRules, nil))
+	projects, err := groupSvc.RetrieveProjects()
+	if err != nil {
+		return "", err
+	}
+
+	var releases []app.ProjectRelease
+	gitLabSvcs := make(map[int]app.GitLabService)
+	for _, project := range projects {
+		tagName := projectTag(env, project)
+		if tagName == "" {
+			continue
+		}
+
+		projectEnv := env
+		projectEnv.ProjectID = strconv.Itoa(project.ID)
+		gitLabSvc := newGitLabService(projectEnv, newGitLabClient(projectEnv), componentRules)
+		tags, err := gitLabSvc.RetrieveTagAndPreviousTag(tagName)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", errors.WithMessagef(err, "project %s", project.PathWithNamespace)
+		}
+
+		gitLabSvcs[project.ID] = gitLabSvc
+		releases = append(releases, app.ProjectRelease{Project: project, LatestTag: tags[0], PreviousTag: tags[1]})
+	}
+	if len(releases) == 0 {
+		return "", errors.New("Cannot find the release tag in any project of the group.")
+	}
+
+	releases, err = groupSvc.RetrieveChangelogs(releases)
+	if err != nil {
+		return "", err
+	}
+
+	var output strings.Builder
+	for _, release := range releases {
+		mrs, err := gitLabSvcs[release.Project.ID].RetrieveMergeRequestDetails(release.MergeRequests, release.LatestTag)
+		if err != nil {
+			return "", errors.WithMessagef(err, "project %s", release.Project.PathWithNamespace)
+		}
+
+		contentSvc, err := newContentService(env, componentRules, release.Project.WebURL)
+		if err != nil {
+			return "", err
+		}
+		content, err := contentSvc.GenerateContent(mrs, release.Issues, release.LatestTag, release.PreviousTag)
+		if err != nil {
+			return "", err
+		}
+
+		output.WriteString(fmt.Sprintf("## [%s](%s) %s\n", release.Project.PathWithNamespace, release.Project.WebURL, release.LatestTag.Name))
+		output.WriteString(content)
+		output.WriteString("\n")
+	}
+	return output.String(), nil
+}
+
+// projectTag returns the tag of the project from PROJECT_TAGS, keyed by
+// project path or id, falling back to PRODUCT_TAG.
+func projectTag(env envConfig, project app.Repo) string {
+	if tag, exists := env.ProjectTags[project.PathWithNamespace]; exists {
+		return tag
+	}
+	if tag, exists := env.ProjectTags[strconv.Itoa(project.ID)]; exists {
+		return tag
+	}
+	return env.ProductTag
+}
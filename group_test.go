@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunGroup(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /groups/mygroup/projects": `[
+			{"id":1,"path_with_namespace":"mygroup/api","web_url":"https://gitlab.example.com/mygroup/api"},
+			{"id":2,"path_with_namespace":"mygroup/web","web_url":"https://gitlab.example.com/mygroup/web"},
+			{"id":3,"path_with_namespace":"mygroup/docs","web_url":"https://gitlab.example.com/mygroup/docs"}
+		]`,
+		"GET /projects/1/repository/tags": `[
+			{"name":"v2.0","commit":{"id":"a2","committed_date":"2024-05-10T00:00:00Z"}},
+			{"name":"v1.0","commit":{"id":"a1","committed_date":"2024-05-01T00:00:00Z"}}
+		]`,
+		"GET /projects/1/repository/commits/a1/refs": `[{"name":"main"}]`,
+		"GET /projects/2/repository/tags": `[
+			{"name":"web-2.0","commit":{"id":"b2","committed_date":"2024-05-12T00:00:00Z"}},
+			{"name":"v2.0","commit":{"id":"b1","committed_date":"2024-05-02T00:00:00Z"}}
+		]`,
+		"GET /projects/2/repository/commits/b1/refs": `[{"name":"main"}]`,
+		"GET /projects/3/repository/tags":            `[]`,
+		"GET /groups/mygroup/merge_requests": `[
+			{"iid":1,"project_id":1,"title":"Fix login","labels":["bug"],"web_url":"https://gitlab.example.com/mygroup/api/-/merge_requests/1","merged_at":"2024-05-05T00:00:00Z"},
+			{"iid":2,"project_id":2,"title":"New page","labels":["feature"],"web_url":"https://gitlab.example.com/mygroup/web/-/merge_requests/2","merged_at":"2024-05-11T00:00:00Z"},
+			{"iid":3,"project_id":2,"title":"Old page","labels":["feature"],"web_url":"https://gitlab.example.com/mygroup/web/-/merge_requests/3","merged_at":"2024-05-01T00:00:00Z"}
+		]`,
+		"GET /groups/mygroup/issues": `[]`,
+	})
+	env := testEnv(endpoint)
+	env.TargetTagRegex = ".*"
+	env.GroupID = "mygroup"
+	env.ProductTag = "v2.0"
+	env.ProjectTags = map[string]string{"mygroup/web": "web-2.0"}
+
+	content, err := runGroup(env)
+	assert.NoError(t, err)
+	assert.Equal(t, "## [mygroup/api](https://gitlab.example.com/mygroup/api) v2.0\n"+
+		"### Release note (2024-05-10)\n"+
+		"#### Fixed bugs\n"+
+		"- Fix login [#1](https://gitlab.example.com/mygroup/api/-/merge_requests/1) ([]())\n"+
+		"\n"+
+		"## [mygroup/web](https://gitlab.example.com/mygroup/web) web-2.0\n"+
+		"### Release note (2024-05-12)\n"+
+		"#### New features\n"+
+		"- New page [#2](https://gitlab.example.com/mygroup/web/-/merge_requests/2) ([]())\n"+
+		"\n", content)
+}
+
+func TestRunGroup_NoReleaseTag(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /groups/mygroup/projects":    `[{"id":1,"path_with_namespace":"mygroup/api"}]`,
+		"GET /projects/1/repository/tags": `[]`,
+	})
+	env := testEnv(endpoint)
+	env.GroupID = "mygroup"
+	env.ProductTag = "v2.0"
+
+	_, err := runGroup(env)
+	assert.EqualError(t, err, "Cannot find the release tag in any project of the group.")
+
+	env.GroupID = ""
+	_, err = runGroup(env)
+	assert.Equal(t, exitConfig, exitCode(err))
+}
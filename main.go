@@ -1,8 +1,11 @@
 package main
 
 import (
+	"log/slog"
+	"os"
+
 	"gitLab-rls-note/app"
-	"gitLab-rls-note/pkg/config"
+	"gitLab-rls-note/pkg/errors"
 	"gitLab-rls-note/store"
 )
 
@@ -16,54 +19,277 @@ type envConfig struct {
 	IssueClosedSeconds int    `mapstructure:"ISSUE_CLOSED_SECONDS"`
 	ZeroTrustCookie    string `mapstructure:"ZERO_TRUST_COOKIE"`
 	IncludeCommits     bool   `mapstructure:"INCLUDE_COMMITS"`
+	IncludeSummary     bool   `mapstructure:"INCLUDE_SUMMARY"`
+
+	SortBy              string            `mapstructure:"SORT_BY"`
+	SortOrder           string            `mapstructure:"SORT_ORDER"`
+	SectionSortBy       map[string]string `mapstructure:"SECTION_SORT_BY"`
+	SectionSortOrder    map[string]string `mapstructure:"SECTION_SORT_ORDER"`
+	PriorityLabelPrefix string            `mapstructure:"PRIORITY_LABEL_PREFIX"`
+
+	GroupBy         string            `mapstructure:"GROUP_BY"`
+	GroupLabelScope string            `mapstructure:"GROUP_LABEL_SCOPE"`
+	GroupPathDepth  int               `mapstructure:"GROUP_PATH_DEPTH"`
+	GroupOtherTitle string            `mapstructure:"GROUP_OTHER_TITLE"`
+	GroupTitles     map[string]string `mapstructure:"GROUP_TITLES"`
+
+	ComponentRulesFile string   `mapstructure:"COMPONENT_RULES_FILE"`
+	IncludePaths       []string `mapstructure:"INCLUDE_PATHS"`
+	IncludeComponents  []string `mapstructure:"INCLUDE_COMPONENTS"`
+
+	Monorepo            bool              `mapstructure:"MONOREPO"`
+	TargetPackage       string            `mapstructure:"TARGET_PACKAGE"`
+	PackagePaths        map[string]string `mapstructure:"PACKAGE_PATHS"`
+	PackagePathTemplate string            `mapstructure:"PACKAGE_PATH_TEMPLATE"`
+
+	GroupID     string            `mapstructure:"GITLAB_GROUP_ID"`
+	ProductTag  string            `mapstructure:"PRODUCT_TAG"`
+	ProjectTags map[string]string `mapstructure:"PROJECT_TAGS"`
+
+	AssetLinksFiles []string `mapstructure:"ASSET_LINKS_FILES"`
+
+	Milestones      []string `mapstructure:"MILESTONES"`
+	InferMilestones bool     `mapstructure:"INFER_MILESTONES"`
+
+	MarkerFallback string `mapstructure:"MARKER_FALLBACK"`
+
+	HistoryFile string `mapstructure:"HISTORY_FILE"`
+
+	SlackWebhookURL      string `mapstructure:"SLACK_WEBHOOK_URL"`
+	MattermostWebhookURL string `mapstructure:"MATTERMOST_WEBHOOK_URL"`
+	TeamsWebhookURL      string `mapstructure:"TEAMS_WEBHOOK_URL"`
+	ChatOverflow         string `mapstructure:"CHAT_OVERFLOW"`
+
+	WebhookURLs    []string `mapstructure:"WEBHOOK_URLS"`
+	WebhookSecret  string   `mapstructure:"WEBHOOK_SECRET"`
+	WebhookRetries int      `mapstructure:"WEBHOOK_RETRIES"`
+
+	SMTPHost               string            `mapstructure:"SMTP_HOST"`
+	SMTPPort               int               `mapstructure:"SMTP_PORT"`
+	SMTPUsername           string            `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword           string            `mapstructure:"SMTP_PASSWORD"`
+	SMTPTLS                string            `mapstructure:"SMTP_TLS"`
+	EmailFrom              string            `mapstructure:"EMAIL_FROM"`
+	EmailTo                []string          `mapstructure:"EMAIL_TO"`
+	EmailProjectRecipients map[string]string `mapstructure:"EMAIL_PROJECT_RECIPIENTS"`
+	EmailSubjectPrefix     string            `mapstructure:"EMAIL_SUBJECT_PREFIX"`
+
+	WikiPublish    bool   `mapstructure:"WIKI_PUBLISH"`
+	WikiPagePrefix string `mapstructure:"WIKI_PAGE_PREFIX"`
+
+	PublishersFile       string `mapstructure:"PUBLISHERS_FILE"`
+	PublishFailurePolicy string `mapstructure:"PUBLISH_FAILURE_POLICY"`
+
+	ServeAddr         string `mapstructure:"SERVE_ADDR"`
+	WebhookToken      string `mapstructure:"GITLAB_WEBHOOK_TOKEN"`
+	ServeWorkers      int    `mapstructure:"SERVE_WORKERS"`
+	ServeQueueSize    int    `mapstructure:"SERVE_QUEUE_SIZE"`
+	ServeDedupSeconds int    `mapstructure:"SERVE_DEDUP_SECONDS"`
+	APIToken          string `mapstructure:"API_TOKEN"`
+	APICacheSeconds   int    `mapstructure:"API_CACHE_SECONDS"`
+
+	LogLevel  string `mapstructure:"LOG_LEVEL"`
+	LogFormat string `mapstructure:"LOG_FORMAT"`
 }
 
 func main() {
-	config.LoadEnvConfig()
-	env := envConfig{}
-	err := config.UnmarshalEnvConfig(&env)
+	os.Exit(runCLI(os.Args[1:], os.Stderr))
+}
+
+// run generates the release note of the latest tag, or of opts.tag, and
+// publishes it.
+func run(env envConfig, opts runOptions) error {
+	publishersCfg, err := loadPublishersConfig(env)
 	if err != nil {
-		panic(err)
+		return configError(err)
 	}
 
-	client := store.NewGitlabClient(
-		env.PersonalToken,
-		env.APIEndpoint,
-		env.ProjectID,
-		env.ZeroTrustCookie,
-	)
-	gitLabSvc := app.NewGitLabService(client, app.Config{
-		TargetBranch:       env.TargetBranch,
-		TargetTagRegex:     env.TargetTagRegex,
-		IssueClosedSeconds: env.IssueClosedSeconds,
-		IncludeCommits:     env.IncludeCommits,
-	})
+	p, err := generateReleaseNote(env, opts.tag, opts.previousTag, linksRelease(publishersCfg))
+	if err != nil {
+		return err
+	}
 
-	tags, err := gitLabSvc.RetrieveTwoLatestTags()
+	if opts.preview() {
+		publish, err := previewRelease(p.gitLabSvc, p.tag, p.note.Markdown(), opts)
+		if err != nil || !publish {
+			return err
+		}
+	}
+
+	publishers, err := newPublishers(publishersCfg, env, p.client, p.gitLabSvc, p.tag)
 	if err != nil {
-		panic(err)
+		return configError(err)
+	}
+	if opts.skipAnnouncements {
+		publishers = withoutAnnouncers(publishers)
 	}
 
-	latestTag, secondLatestTag := tags[0], tags[1]
-	startDate := secondLatestTag.Commit.CommittedDate
-	endDate := latestTag.Commit.CommittedDate
+	results, publishErr := app.PublishAll(publishers, p.note, publishersCfg.FailurePolicy)
+	logPublisherResults(p.tag.Name, opts.runID, results)
+	if result, ok := gitLabResult(results); ok {
+		if err := recordHistory(opts.history, opts.runID, env.ProjectID, p.tag.Name, result); err != nil {
+			return err
+		}
+	}
+	return publishErr
+}
 
-	mrs, issues, err := gitLabSvc.RetrieveChangelogsByStartAndEndDate(startDate, endDate)
+// generated is the release note of a tag with the services generating it.
+type generated struct {
+	client    app.GitLabClient
+	gitLabSvc app.GitLabService
+	tag       app.Tag
+	note      app.ReleaseNote
+}
+
+// generateReleaseNote generates the release note of the tag since the
+// previous tag, by default the latest tag since the tag preceding it. The
+// project is only retrieved for the links of the summary, or of the release
+// with withURL, and to resolve a project path.
+func generateReleaseNote(env envConfig, tagName, previousTagName string, withURL bool) (generated, error) {
+	componentRules, err := loadComponentRules(env)
 	if err != nil {
-		panic(err)
+		return generated{}, configError(err)
+	}
+
+	assetLinks, err := loadAssetLinks(env.AssetLinksFiles)
+	if err != nil {
+		return generated{}, configError(err)
+	}
+
+	if err := app.ValidateMarkerFallback(env.MarkerFallback); err != nil {
+		return generated{}, configError(err)
+	}
+
+	client := newGitLabClient(env)
+	gitLabSvc := app.NewGitLabService(client, newGitLabConfig(env, componentRules, assetLinks))
+
+	// A project path is resolved before the other calls, a wrong
+	// GITLAB_PROJECT_ID fails early.
+	var projectURL string
+	if withURL || env.IncludeSummary || !store.IsProjectID(env.ProjectID) {
+		repo, err := resolveProject(gitLabSvc)
+		if err != nil {
+			return generated{}, err
+		}
+		projectURL = repo.WebURL
 	}
 
-	contentSvc, err := app.NewContentService(env.TimeZone)
+	var tags []app.Tag
+	if tagName != "" {
+		tags, err = gitLabSvc.RetrieveTagRange(tagName, previousTagName)
+	} else {
+		tags, err = gitLabSvc.RetrieveTwoLatestTags()
+	}
 	if err != nil {
-		panic(err)
+		return generated{}, err
 	}
-	content, err := contentSvc.GenerateContent(mrs, issues, endDate)
+
+	if len(tags) < 2 {
+		return generated{}, errors.WithNotFound(errors.New("Cannot find the latest tag matching TARGET_TAG_REGEX on the target branch."), app.TagNotFoundCode)
+	}
+
+	latestTag, secondLatestTag := tags[0], tags[1]
+	mrs, issues, err := gitLabSvc.RetrieveChangelogs(latestTag, secondLatestTag)
 	if err != nil {
-		panic(err)
+		return generated{}, err
 	}
 
-	err = gitLabSvc.Publish(latestTag, content)
+	contentSvc, err := newContentService(env, componentRules, projectURL)
+	if err != nil {
+		return generated{}, configError(err)
+	}
+	note, err := contentSvc.GenerateReleaseNote(mrs, issues, latestTag, secondLatestTag)
 	if err != nil {
-		panic(err)
+		return generated{}, err
 	}
+	note.Milestones = gitLabSvc.ReleaseMilestones(mrs, issues)
+
+	return generated{client: client, gitLabSvc: gitLabSvc, tag: latestTag, note: note}, nil
+}
+
+// resolveProject retrieves the project, resolving a namespaced project path
+// to its numeric id. A missing project is a config error.
+func resolveProject(gitLabSvc app.GitLabService) (app.Repo, error) {
+	repo, err := gitLabSvc.RetrieveRepo()
+	if errors.IsNotFound(err) {
+		return app.Repo{}, configError(err)
+	}
+	return repo, err
+}
+
+func loadComponentRules(env envConfig) (app.ComponentRules, error) {
+	if env.ComponentRulesFile == "" {
+		if len(env.IncludeComponents) > 0 {
+			// Without rules no merge request has a component, they would all be filtered out.
+			return nil, errors.New("INCLUDE_COMPONENTS requires COMPONENT_RULES_FILE.")
+		}
+		return nil, nil
+	}
+	return app.LoadComponentRules(env.ComponentRulesFile)
+}
+
+func newGitLabClient(env envConfig) app.GitLabClient {
+	return store.NewGitlabClient(
+		env.PersonalToken,
+		env.APIEndpoint,
+		env.ProjectID,
+		env.ZeroTrustCookie,
+	)
+}
+
+func newGitLabService(env envConfig, client app.GitLabClient, componentRules app.ComponentRules) app.GitLabService {
+	return app.NewGitLabService(client, newGitLabConfig(env, componentRules, nil))
+}
+
+func newGitLabConfig(env envConfig, componentRules app.ComponentRules, assetLinks []app.ReleaseLink) app.Config {
+	return app.Config{
+		TargetBranch:       env.TargetBranch,
+		TargetTagRegex:     env.TargetTagRegex,
+		IssueClosedSeconds: env.IssueClosedSeconds,
+		IncludeCommits:     env.IncludeCommits,
+		IncludeChanges:     env.GroupBy == app.GroupByPath || env.GroupBy == app.GroupByComponent,
+		IncludePaths:       env.IncludePaths,
+		IncludeComponents:  env.IncludeComponents,
+		ComponentRules:     componentRules,
+
+		Monorepo:            env.Monorepo,
+		TargetPackage:       env.TargetPackage,
+		PackagePaths:        env.PackagePaths,
+		PackagePathTemplate: env.PackagePathTemplate,
+
+		AssetLinks: assetLinks,
+
+		Milestones:      env.Milestones,
+		InferMilestones: env.InferMilestones,
+
+		MarkerFallback: env.MarkerFallback,
+
+		Logger: slog.Default().With("project", env.ProjectID),
+	}
+}
+
+func newContentService(env envConfig, componentRules app.ComponentRules, projectURL string) (app.ContentService, error) {
+	return app.NewContentService(app.ContentConfig{
+		TimeZone:           env.TimeZone,
+		IncludeSummary:     env.IncludeSummary,
+		IncludeCommits:     env.IncludeCommits,
+		IssueClosedSeconds: env.IssueClosedSeconds,
+		ProjectURL:         projectURL,
+
+		SortBy:              env.SortBy,
+		SortOrder:           env.SortOrder,
+		SectionSortBy:       env.SectionSortBy,
+		SectionSortOrder:    env.SectionSortOrder,
+		PriorityLabelPrefix: env.PriorityLabelPrefix,
+
+		GroupBy:         env.GroupBy,
+		GroupLabelScope: env.GroupLabelScope,
+		GroupPathDepth:  env.GroupPathDepth,
+		GroupOtherTitle: env.GroupOtherTitle,
+		GroupTitles:     env.GroupTitles,
+		ComponentRules:  componentRules,
+
+		Logger: slog.Default().With("project", env.ProjectID),
+	})
 }
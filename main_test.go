@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitLab serves canned responses keyed by method and escaped path, and
+// counts the requests it receives.
+type fakeGitLab struct {
+	mu        sync.Mutex
+	responses map[string]string
+	requests  map[string]int
+}
+
+func newFakeGitLab(t *testing.T, responses map[string]string) (*fakeGitLab, string) {
+	f := &fakeGitLab{responses: responses, requests: make(map[string]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		key := r.Method + " " + strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4")
+
+		f.mu.Lock()
+		f.requests[key]++
+		resp, exists := f.responses[key]
+		f.mu.Unlock()
+
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Not Found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(server.Close)
+	return f, server.URL + "/api/v4"
+}
+
+func (f *fakeGitLab) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[key]
+}
+
+// testEnv is the env config of a project on the fake GitLab.
+func testEnv(endpoint string) envConfig {
+	return envConfig{
+		PersonalToken:  "token",
+		APIEndpoint:    endpoint,
+		ProjectID:      "42",
+		TargetBranch:   "main",
+		TargetTagRegex: "^v.*$",
+		TimeZone:       "UTC",
+	}
+}
+
+func TestGenerateReleaseNote_ProjectNotFound(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{})
+	env := testEnv(endpoint)
+	env.ProjectID = "mygroup/missing"
+
+	_, err := generateReleaseNote(env, "", "", false)
+	assert.Equal(t, invalidConfigCode, errors.ErrorCode(err))
+	assert.Contains(t, err.Error(), `Project "mygroup/missing" not found`)
+	// No other call is made with the unresolved project.
+	assert.Equal(t, 1, fake.count("GET /projects/mygroup%2Fmissing"))
+	assert.Len(t, fake.requests, 1)
+}
+
+func TestValidateConfig_ProjectPath(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/mygroup%2Fproject": `{"id":42}`,
+	})
+	env := testEnv(endpoint)
+
+	env.ProjectID = "mygroup/project"
+	assert.NoError(t, validateConfig(env))
+
+	env.ProjectID = "mygroup/missing"
+	err := validateConfig(env)
+	assert.Equal(t, invalidConfigCode, errors.ErrorCode(err))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gitLab-rls-note/pkg/config"
+)
+
+// optionUsages documents the env options, see the Options of the README.
+var optionUsages = map[string]string{
+	"GITLAB_PERSONAL_TOKEN": "A GitLab personal access token with the api permission",
+	"GITLAB_API_ENDPOINT":   "The GitLab API endpoint, eg: https://gitlab.com/api/v4",
+	"GITLAB_PROJECT_ID":     "The project id or path, eg: mygroup/subgroup/project",
+	"TARGET_BRANCH":         "The branch to look for release tags, eg: main",
+	"TARGET_TAG_REGEX":      "The regular expression of the release tags, eg: ^release-.*$",
+	"TZ":                    "The timezone of the release notes, eg: Asia/Saigon",
+	"ISSUE_CLOSED_SECONDS":  "The seconds to search after the last commit of a tag",
+	"ZERO_TRUST_COOKIE":     "The cookie passing the Cloudflare zero trust",
+	"INCLUDE_COMMITS":       "Add the commits of the merge requests",
+	"INCLUDE_SUMMARY":       "Add a summary block on top of the release note",
+
+	"SORT_BY":               "Sort the entries by date, iid, title, author or priority",
+	"SORT_ORDER":            "The sort direction, asc or desc (default asc)",
+	"SECTION_SORT_BY":       "SORT_BY per section, eg: bug:priority;feature:title",
+	"SECTION_SORT_ORDER":    "SORT_ORDER per section, eg: bug:desc",
+	"PRIORITY_LABEL_PREFIX": "The prefix of the priority labels (default priority::)",
+
+	"GROUP_BY":          "Group the entries by label, path, scope, component or milestone",
+	"GROUP_LABEL_SCOPE": "The scope of the labels of GROUP_BY=label (default component)",
+	"GROUP_PATH_DEPTH":  "The directories of GROUP_BY=path (default 1)",
+	"GROUP_OTHER_TITLE": "The heading of the ungrouped entries (default Other)",
+	"GROUP_TITLES":      "The headings of the groups, eg: api:API;web:Web",
+
+	"COMPONENT_RULES_FILE": "The file mapping the changed files to components, CODEOWNERS syntax",
+	"INCLUDE_PATHS":        "Only keep the merge requests changing these paths, eg: services/billing/",
+	"INCLUDE_COMPONENTS":   "Only keep the merge requests changing these components, eg: billing;web",
+
+	"MONOREPO":              "Release the packages of a monorepo separately",
+	"TARGET_PACKAGE":        "The package to release in monorepo mode (default the package of the latest tag)",
+	"PACKAGE_PATHS":         "The path of each package, eg: billing:/services/billing/",
+	"PACKAGE_PATH_TEMPLATE": "The path of the other packages (default {package}/)",
+
+	"GITLAB_GROUP_ID": "The group id or path of a group release",
+	"PRODUCT_TAG":     "The release tag of every project of a group release, eg: v2.1.0",
+	"PROJECT_TAGS":    "The release tag per project of a group release, eg: mygroup/api:v2.1.0",
+
+	"ASSET_LINKS_FILES": "The YAML/JSON/TOML files listing the release asset links",
+	"MILESTONES":        "The milestones of the release, eg: 17.1;Q3 2024",
+	"INFER_MILESTONES":  "Use the milestones of the merge requests and issues when MILESTONES is empty",
+	"MARKER_FALLBACK":   "How to update a release description without markers: overwrite, append, prepend or refuse",
+	"HISTORY_FILE":      "The file saving the published release descriptions (default .rlsnote/history.jsonl)",
+
+	"SLACK_WEBHOOK_URL":      "The Slack incoming webhook announcing the release",
+	"MATTERMOST_WEBHOOK_URL": "The Mattermost incoming webhook announcing the release",
+	"TEAMS_WEBHOOK_URL":      "The Microsoft Teams incoming webhook announcing the release",
+	"CHAT_OVERFLOW":          "Split or truncate the notes exceeding the chat limits (default split)",
+
+	"WEBHOOK_URLS":    "The URLs receiving the release note as JSON",
+	"WEBHOOK_SECRET":  "The secret signing the webhook payloads",
+	"WEBHOOK_RETRIES": "The retries of a failed webhook delivery, -1 to disable (default 3)",
+
+	"SMTP_HOST":                "The SMTP server sending the release announcement email",
+	"SMTP_PORT":                "The port of the SMTP server (default 587)",
+	"SMTP_USERNAME":            "The username of the SMTP server",
+	"SMTP_PASSWORD":            "The password of the SMTP server",
+	"SMTP_TLS":                 "starttls, or none for an unencrypted connection (default starttls)",
+	"EMAIL_FROM":               "The sender of the email",
+	"EMAIL_TO":                 "The recipients of the email",
+	"EMAIL_PROJECT_RECIPIENTS": "The comma-separated recipients per project, eg: mygroup/api:api@example.com",
+	"EMAIL_SUBJECT_PREFIX":     "The prefix of the email subject",
+
+	"WIKI_PUBLISH":     "Also write the release note to a wiki page",
+	"WIKI_PAGE_PREFIX": "The title of the wiki index page (default Releases)",
+
+	"PUBLISHERS_FILE":        "The YAML/JSON/TOML file listing the targets of the release note",
+	"PUBLISH_FAILURE_POLICY": "fail_fast, fail_at_end or ignore (default fail_at_end)",
+
+	"SERVE_ADDR":           "The address of the serve command (default :8080)",
+	"GITLAB_WEBHOOK_TOKEN": "The secret token of the GitLab hooks",
+	"SERVE_WORKERS":        "The release notes generated concurrently (default 2)",
+	"SERVE_QUEUE_SIZE":     "The tag push events waiting for a worker (default 100)",
+	"SERVE_DEDUP_SECONDS":  "How long a released tag is ignored (default 600)",
+	"API_TOKEN":            "The bearer token of the notes API, disabled without it",
+	"API_CACHE_SECONDS":    "How long the notes API caches a note, negative to disable (default 300)",
+
+	"LOG_LEVEL":  "debug, info, warn or error (default info)",
+	"LOG_FORMAT": "The format of the logs, text or json (default text)",
+}
+
+// optionValue is the flag of an env option, its value has the env format.
+type optionValue struct {
+	env   string
+	kind  reflect.Kind
+	value string
+}
+
+func (v *optionValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *optionValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *optionValue) IsBoolFlag() bool {
+	return v.kind == reflect.Bool
+}
+
+// typeName is the value placeholder of the usage.
+func (v *optionValue) typeName() string {
+	switch v.kind {
+	case reflect.Bool:
+		return ""
+	case reflect.Slice:
+		return "a;b"
+	case reflect.Map:
+		return "k:v;k2:v2"
+	default:
+		return v.kind.String()
+	}
+}
+
+// optionFlag is the flag name of an env option, eg: --target-tag-regex.
+func optionFlag(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// addOptionFlags adds a flag per option of the env config.
+func addOptionFlags(fs *flag.FlagSet, env interface{}) {
+	typ := reflect.TypeOf(env).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" {
+			continue
+		}
+		usage := optionUsages[name]
+		if usage == "" {
+			usage = name
+		}
+		fs.Var(&optionValue{env: name, kind: field.Type.Kind()}, optionFlag(name), usage)
+	}
+}
+
+// overrideOptions overrides the env config with the option flags set on the
+// command line.
+func overrideOptions(fs *flag.FlagSet, env interface{}) error {
+	values := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if v, ok := f.Value.(*optionValue); ok {
+			values[v.env] = v.value
+		}
+	})
+	return config.OverrideConfig(env, values)
+}
+
+// printFlags prints the flags selected by options, the env options or the
+// flags of the command.
+func printFlags(w io.Writer, fs *flag.FlagSet, options bool) {
+	var flags []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := f.Value.(*optionValue); ok == options {
+			flags = append(flags, f)
+		}
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+
+	for _, f := range flags {
+		name := ""
+		if v, ok := f.Value.(*optionValue); ok {
+			name = v.typeName()
+		} else {
+			name, _ = flag.UnquoteUsage(f)
+		}
+		line := "  --" + f.Name
+		if name != "" {
+			line += " " + name
+		}
+		fmt.Fprintf(w, "%s\n    \t%s", line, f.Usage)
+		if v, ok := f.Value.(*optionValue); ok {
+			fmt.Fprintf(w, " [$%s]", v.env)
+		} else if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			fmt.Fprintf(w, " (default %s)", f.DefValue)
+		}
+		fmt.Fprintln(w)
+	}
+}
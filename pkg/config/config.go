@@ -1,10 +1,10 @@
 package config
 
 import (
-	"log"
 	"path"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"gitLab-rls-note/pkg/errors"
@@ -12,19 +12,18 @@ import (
 	"github.com/spf13/viper"
 )
 
-// LoadEnvConfig loads environment variables using viper global instance
-func LoadEnvConfig() {
+// LoadEnvConfig loads environment variables using viper global instance,
+// and the .env file next to the caller. The error of reading the file is
+// returned, the environment variables are loaded anyway.
+func LoadEnvConfig() error {
 	_, f, _, _ := runtime.Caller(1)
 	pwd := path.Dir(f)
 	file := path.Join(pwd, ".env")
 
 	viper.SetConfigFile(file)
 	err := viper.ReadInConfig()
-	if err != nil {
-		log.Printf("Not loading env from file: %s", err.Error())
-	}
-
 	viper.AutomaticEnv()
+	return errors.WithStack(err)
 }
 
 // UnmarshalEnvConfig stores environment variables into a defined struct.
@@ -55,52 +54,133 @@ func UnmarshalEnvConfig(o interface{}) (err error) {
 			continue
 		}
 
-		fieldKind := structField.Kind()
-		fieldName := typeField.Tag.Get(tag)
-
-		switch fieldKind {
-		case reflect.Struct:
+		if structField.Kind() == reflect.Struct {
 			err = UnmarshalEnvConfig(structField.Addr().Interface())
 			if err != nil {
 				return
 			}
+			continue
+		}
 
-		case reflect.String:
-			structField.SetString(viper.GetString(fieldName))
-		case reflect.Bool:
-			structField.SetBool(viper.GetBool(fieldName))
-		case reflect.Int:
-			structField.SetInt(int64(viper.GetInt(fieldName)))
-
-		case reflect.Slice:
-			_, ok := structField.Interface().([]string)
-			if !ok {
-				return errors.New("Only support string slice.")
-			}
+		fieldName := typeField.Tag.Get(tag)
+		err = setField(structField, fieldName, viper.GetString(fieldName))
+		if err != nil {
+			return
+		}
+	}
 
-			elems := strings.Split(viper.GetString(fieldName), ";")
-			s := make([]string, 0)
-			s = append(s, elems...)
-			structField.Set(reflect.ValueOf(s))
+	return
+}
 
-		case reflect.Map:
-			_, ok := structField.Interface().(map[string]string)
-			if !ok {
-				return errors.New("Only support map[string]string map.")
-			}
+// OverrideConfig overrides the fields of a struct populated by UnmarshalEnvConfig
+// with the given values, keyed by env name. Keys are case-insensitive, values
+// use the same format as the environment variables.
+func OverrideConfig(o interface{}, values map[string]string) (err error) {
+	if o == nil || len(values) == 0 {
+		return
+	}
 
-			m := make(map[string]string)
-			elems := strings.Split(viper.GetString(fieldName), ";")
-			for _, elem := range elems {
-				kv := strings.Split(elem, ":")
-				m[kv[0]] = kv[1]
+	const tag string = "mapstructure"
+	typ := reflect.TypeOf(o).Elem()
+	val := reflect.ValueOf(o).Elem()
+
+	if typ.Kind() != reflect.Struct {
+		return errors.New("Output must be a struct")
+	}
+
+	lowerValues := make(map[string]string, len(values))
+	for k, v := range values {
+		lowerValues[strings.ToLower(k)] = v
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := val.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+
+		if structField.Kind() == reflect.Struct {
+			err = OverrideConfig(structField.Addr().Interface(), values)
+			if err != nil {
+				return
 			}
-			structField.Set(reflect.ValueOf(m))
+			continue
+		}
 
-		default:
-			return errors.Errorf("Unsupported field kind: %s", fieldKind)
+		fieldName := typeField.Tag.Get(tag)
+		value, exists := lowerValues[strings.ToLower(fieldName)]
+		if !exists {
+			continue
+		}
+
+		err = setField(structField, fieldName, value)
+		if err != nil {
+			return
 		}
 	}
 
 	return
 }
+
+// setField sets a field from the raw value of its env, an empty value sets
+// the zero value of bool and int fields.
+func setField(field reflect.Value, name, raw string) error {
+	switch kind := field.Kind(); kind {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b := false
+		if raw = strings.TrimSpace(raw); raw != "" {
+			var err error
+			if b, err = strconv.ParseBool(raw); err != nil {
+				return errors.Wrapf(err, "Invalid bool of %s", name)
+			}
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n := 0
+		if raw = strings.TrimSpace(raw); raw != "" {
+			var err error
+			if n, err = strconv.Atoi(raw); err != nil {
+				return errors.Wrapf(err, "Invalid int of %s", name)
+			}
+		}
+		field.SetInt(int64(n))
+
+	case reflect.Slice:
+		if _, ok := field.Interface().([]string); !ok {
+			return errors.New("Only support string slice.")
+		}
+
+		s := make([]string, 0)
+		for _, elem := range strings.Split(raw, ";") {
+			if elem != "" {
+				s = append(s, elem)
+			}
+		}
+		field.Set(reflect.ValueOf(s))
+
+	case reflect.Map:
+		if _, ok := field.Interface().(map[string]string); !ok {
+			return errors.New("Only support map[string]string map.")
+		}
+
+		m := make(map[string]string)
+		for _, elem := range strings.Split(raw, ";") {
+			if elem == "" {
+				continue
+			}
+			kv := strings.SplitN(elem, ":", 2)
+			if len(kv) != 2 {
+				return errors.Errorf("Invalid map entry %q of %s", elem, name)
+			}
+			m[kv[0]] = kv[1]
+		}
+		field.Set(reflect.ValueOf(m))
+
+	default:
+		return errors.Errorf("Unsupported field kind: %s", kind)
+	}
+	return nil
+}
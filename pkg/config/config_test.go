@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Name    string            `mapstructure:"TEST_NAME"`
+	Enabled bool              `mapstructure:"TEST_ENABLED"`
+	Count   int               `mapstructure:"TEST_COUNT"`
+	Paths   []string          `mapstructure:"TEST_PATHS"`
+	Titles  map[string]string `mapstructure:"TEST_TITLES"`
+}
+
+func setViper(t *testing.T, values map[string]string) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	for k, v := range values {
+		viper.Set(k, v)
+	}
+}
+
+func TestUnmarshalEnvConfig(t *testing.T) {
+	setViper(t, map[string]string{
+		"TEST_NAME":    "release",
+		"TEST_ENABLED": "true",
+		"TEST_COUNT":   "3",
+		"TEST_PATHS":   "services/billing/;;web/;",
+		"TEST_TITLES":  "bug:priority;docs:https://example.com/docs;",
+	})
+
+	var cfg testConfig
+	assert.NoError(t, UnmarshalEnvConfig(&cfg))
+	assert.Equal(t, testConfig{
+		Name:    "release",
+		Enabled: true,
+		Count:   3,
+		Paths:   []string{"services/billing/", "web/"},
+		Titles:  map[string]string{"bug": "priority", "docs": "https://example.com/docs"},
+	}, cfg)
+}
+
+func TestUnmarshalEnvConfig_Empty(t *testing.T) {
+	setViper(t, nil)
+
+	var cfg testConfig
+	assert.NoError(t, UnmarshalEnvConfig(&cfg))
+	assert.Equal(t, []string{}, cfg.Paths)
+	assert.Equal(t, map[string]string{}, cfg.Titles)
+}
+
+func TestUnmarshalEnvConfig_InvalidMapEntry(t *testing.T) {
+	setViper(t, map[string]string{"TEST_TITLES": "bug:priority;feature"})
+
+	var cfg testConfig
+	err := UnmarshalEnvConfig(&cfg)
+	assert.EqualError(t, err, `Invalid map entry "feature" of TEST_TITLES`)
+}
+
+func TestOverrideConfig(t *testing.T) {
+	cfg := testConfig{Name: "release", Count: 3, Paths: []string{"web/"}}
+	err := OverrideConfig(&cfg, map[string]string{
+		"test_enabled": "1",
+		"TEST_PATHS":   "services/;libs/",
+		"TEST_TITLES":  "api:API",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig{
+		Name:    "release",
+		Enabled: true,
+		Count:   3,
+		Paths:   []string{"services/", "libs/"},
+		Titles:  map[string]string{"api": "API"},
+	}, cfg)
+}
+
+func TestInvalidValues(t *testing.T) {
+	tcs := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"TEST_ENABLED", "yes", "Invalid bool of TEST_ENABLED"},
+		{"TEST_COUNT", "three", "Invalid int of TEST_COUNT"},
+		{"TEST_TITLES", "api", `Invalid map entry "api" of TEST_TITLES`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			// The env and the overrides parse the values alike.
+			setViper(t, map[string]string{tc.name: tc.value})
+			var cfg testConfig
+			err := UnmarshalEnvConfig(&cfg)
+			assert.ErrorContains(t, err, tc.err)
+
+			err = OverrideConfig(&cfg, map[string]string{tc.name: tc.value})
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+// Package diff renders line-based unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines around each change.
+const ContextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// i and j are the line indexes in a and b.
+	i, j int
+}
+
+// Unified returns the unified diff of a and b, or an empty string when they
+// have the same lines.
+func Unified(a, b, fromName, toName string) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	ops := diffLines(aLines, bLines)
+
+	changed := false
+	for _, o := range ops {
+		if o.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for _, hunk := range hunks(ops) {
+		aStart, bStart := ops[hunk[0]].i, ops[hunk[0]].j
+		aCount, bCount := 0, 0
+		for _, o := range ops[hunk[0]:hunk[1]] {
+			if o.kind != opInsert {
+				aCount++
+			}
+			if o.kind != opDelete {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, o := range ops[hunk[0]:hunk[1]] {
+			switch o.kind {
+			case opEqual:
+				out.WriteString(" " + aLines[o.i] + "\n")
+			case opDelete:
+				out.WriteString("-" + aLines[o.i] + "\n")
+			case opInsert:
+				out.WriteString("+" + bLines[o.j] + "\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes the edit script of the longest common subsequence.
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, op{opEqual, i, j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, op{opInsert, i, j})
+			j++
+		default:
+			ops = append(ops, op{opDelete, i, j})
+			i++
+		}
+	}
+	return ops
+}
+
+// hunks groups the changes with their context lines, returning the start and
+// end indexes of each hunk in ops.
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	for k := 0; k < len(ops); k++ {
+		if ops[k].kind == opEqual {
+			continue
+		}
+
+		start := k - ContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			// Merge the next change when it is within twice the context.
+			next := end
+			for next < len(ops) && ops[next].kind == opEqual {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*ContextLines {
+				end = next
+				continue
+			}
+			end += ContextLines
+			if end > len(ops) {
+				end = len(ops)
+			}
+			break
+		}
+
+		if len(result) > 0 && start <= result[len(result)-1][1] {
+			result[len(result)-1][1] = end
+		} else {
+			result = append(result, [2]int{start, end})
+		}
+		k = end - 1
+	}
+	return result
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,32 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	tcs := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"Same", "a\nb\n", "a\r\nb", ""},
+		{"Insert into empty", "", "a\nb", "--- current\n+++ generated\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"Change with context",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9",
+			"1\n2\n3\n4\nfive\n6\n7\n8\n9",
+			"--- current\n+++ generated\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"},
+		{"Separate hunks",
+			"a\n1\n2\n3\n4\n5\n6\n7\n8\nb",
+			"A\n1\n2\n3\n4\n5\n6\n7\n8\nB",
+			"--- current\n+++ generated\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -7,4 +7,4 @@\n 6\n 7\n 8\n-b\n+B\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Unified(tc.a, tc.b, "current", "generated"))
+		})
+	}
+}
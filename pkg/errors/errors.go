@@ -19,6 +19,13 @@ func As(err error, target interface{}) bool {
 	return errors.As(err, target)
 }
 
+// Unwrap returns the result of calling the Unwrap method on err, if any.
+//
+// This actually calls errors.Unwrap() of the standard package.
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
+
 // WithCode annotates err with a code.
 func WithCode(err error, code string) error {
 	return &withCode{cause: err, code: code, stack: callers()}
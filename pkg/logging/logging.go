@@ -0,0 +1,72 @@
+// Package logging builds the leveled structured loggers of the tool, the
+// secrets are redacted from the records.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// Redacted replaces the secrets.
+	Redacted = "[REDACTED]"
+)
+
+// secretKeys are the parts of the attribute keys holding secrets.
+var secretKeys = []string{"token", "cookie", "password", "secret", "authorization"}
+
+// secretParamRegex matches the secrets of the URL query strings.
+var secretParamRegex = regexp.MustCompile(`(?i)((?:private_token|access_token|token|secret)=)[^&\s]+`)
+
+// New returns a logger writing to w in the format, text by default, from
+// the level, info by default.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, errors.Errorf("Unsupported log level: %s", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: Redact}
+	switch format {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, errors.Errorf("Unsupported log format: %s", format)
+	}
+}
+
+// Redact hides the values of the secret attributes and the secrets of the
+// URL query strings, it is the ReplaceAttr of the handlers.
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+
+	var value string
+	switch v := a.Value.Any().(type) {
+	case string:
+		value = v
+	case error:
+		value = v.Error()
+	default:
+		return a
+	}
+	if secretParamRegex.MatchString(value) {
+		return slog.String(a.Key, secretParamRegex.ReplaceAllString(value, "${1}"+Redacted))
+	}
+	return a
+}
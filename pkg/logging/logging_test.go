@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew_RedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "debug")
+	assert.NoError(t, err)
+
+	logger.Debug("GitLab request",
+		"path", "/projects/42/repository/tags",
+		"query", "page=2&private_token=glpat-abc",
+		"Private-Token", "glpat-abc",
+		"cookie", "CF_Authorization=xyz",
+	)
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "/projects/42/repository/tags", record["path"])
+	assert.Equal(t, "page=2&private_token="+Redacted, record["query"])
+	assert.Equal(t, Redacted, record["Private-Token"])
+	assert.Equal(t, Redacted, record["cookie"])
+	assert.NotContains(t, buf.String(), "glpat-abc")
+	assert.NotContains(t, buf.String(), "xyz")
+}
+
+func TestNew_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatText, "warn")
+	assert.NoError(t, err)
+
+	logger.Info("hidden")
+	logger.Warn("shown", "tag", "v1.2.0")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), `level=WARN msg=shown tag=v1.2.0`)
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "xml", "")
+	assert.Error(t, err)
+
+	_, err = New(&bytes.Buffer{}, "", "verbose")
+	assert.Error(t, err)
+}
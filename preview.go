@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/diff"
+	"gitLab-rls-note/pkg/errors"
+)
+
+// errDescriptionDiffers is returned with --fail-on-diff when the release
+// description would change.
+var errDescriptionDiffers = errors.WithCode(errors.New("The release description differs from the generated content."), "description_differs")
+
+// runOptions control a run: the history of the published releases, the
+// released tag and the diff preview of the release description before
+// publishing.
+type runOptions struct {
+	runID   string
+	history app.HistoryStore
+	// tag releases this tag instead of the latest one.
+	tag string
+	// previousTag releases the changes since this tag instead of the one
+	// preceding tag, it requires tag.
+	previousTag string
+	// skipAnnouncements doesn't publish to the announcers, eg: chats.
+	skipAnnouncements bool
+
+	// diff prints the diff and doesn't publish.
+	diff bool
+	// confirm prints the diff and asks for a confirmation before publishing.
+	confirm bool
+	// failOnDiff prints the diff and fails when there is any, without publishing.
+	failOnDiff bool
+}
+
+func (o runOptions) preview() bool {
+	return o.diff || o.confirm || o.failOnDiff
+}
+
+// previewRelease prints the diff between the current and the generated release
+// description, and returns whether to go on publishing. An unchanged
+// description is published without confirmation, Publish still reconciles
+// the rest of the release and the other publishers run.
+func previewRelease(gitLabSvc app.GitLabService, tag app.Tag, content string, opts runOptions) (bool, error) {
+	current, next, err := gitLabSvc.PreviewDescription(tag, content)
+	if err != nil {
+		return false, err
+	}
+
+	// The normalized descriptions are compared, as Publish does, so that a
+	// release GitLab stored with other line endings shows no changes.
+	d := diff.Unified(app.NormalizeDescription(current), app.NormalizeDescription(next), fmt.Sprintf("release %s (current)", tag.Name), fmt.Sprintf("release %s (generated)", tag.Name))
+	if d == "" {
+		fmt.Println("No changes.")
+		return opts.confirm, nil
+	}
+	fmt.Print(d)
+
+	switch {
+	case opts.failOnDiff:
+		return false, errDescriptionDiffers
+	case opts.confirm:
+		return confirm(os.Stdin, os.Stdout, fmt.Sprintf("Publish release %s?", tag.Name))
+	default:
+		return false, nil
+	}
+}
+
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.WithStack(err)
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
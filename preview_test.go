@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitLab-rls-note/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewRelease_Unchanged(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{})
+	env := testEnv(endpoint)
+	gitLabSvc := newGitLabService(env, newGitLabClient(env), nil)
+	_, description, err := gitLabSvc.PreviewDescription(app.Tag{Name: "v1.0.0"}, "- change")
+	assert.NoError(t, err)
+	release, err := json.Marshal(app.Release{Name: "v1.0.0", Description: description})
+	assert.NoError(t, err)
+	fake.responses["GET /projects/42/releases/v1.0.0"] = string(release)
+
+	tag := app.Tag{Name: "v1.0.0", Release: app.Release{Name: "v1.0.0"}}
+	tcs := []struct {
+		name    string
+		opts    runOptions
+		publish bool
+	}{
+		{"diff", runOptions{diff: true}, false},
+		{"fail on diff", runOptions{failOnDiff: true}, false},
+		{"confirm", runOptions{confirm: true}, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			publish, err := previewRelease(gitLabSvc, tag, "- change", tc.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.publish, publish)
+		})
+	}
+}
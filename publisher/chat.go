@@ -0,0 +1,179 @@
+// Package publisher sends release notes to destinations other than the GitLab
+// release.
+package publisher
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	// OverflowSplit posts a note exceeding the message limits as several messages.
+	OverflowSplit = "split"
+	// OverflowTruncate posts a single message ending with a link to the release.
+	OverflowTruncate = "truncate"
+
+	fullReleaseText = "See the full release note"
+)
+
+var markdownLinkRegex = regexp.MustCompile(`\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// ChatConfig configures a chat publisher.
+type ChatConfig struct {
+	WebhookURL string
+	// Overflow is OverflowSplit or OverflowTruncate, notes are split by default.
+	Overflow   string
+	HTTPClient *http.Client
+}
+
+// ChatPublisher posts release notes to the incoming webhook of a chat.
+type ChatPublisher struct {
+	name     string
+	config   ChatConfig
+	messages func(note app.ReleaseNote, overflow string) []interface{}
+}
+
+// ValidateOverflow checks that overflow is a supported overflow policy.
+func ValidateOverflow(overflow string) error {
+	switch overflow {
+	case "", OverflowSplit, OverflowTruncate:
+		return nil
+	default:
+		return errors.Errorf("Unsupported chat overflow: %s", overflow)
+	}
+}
+
+func newChatPublisher(name string, config ChatConfig, messages func(app.ReleaseNote, string) []interface{}) (*ChatPublisher, error) {
+	if config.WebhookURL == "" {
+		return nil, errors.Errorf("Missing %s webhook URL.", name)
+	}
+	if err := ValidateOverflow(config.Overflow); err != nil {
+		return nil, err
+	}
+	if config.Overflow == "" {
+		config.Overflow = OverflowSplit
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &ChatPublisher{name: name, config: config, messages: messages}, nil
+}
+
+// Name returns the name of the chat, eg: Slack.
+func (p *ChatPublisher) Name() string {
+	return p.name
+}
+
+// Announces returns true, chats are not posted to again on reruns.
+func (p *ChatPublisher) Announces() bool {
+	return true
+}
+
+// Publish posts the note, stopping at the first message that fails.
+func (p *ChatPublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	for _, message := range p.messages(note, p.config.Overflow) {
+		if err := postJSON(p.config.HTTPClient, p.config.WebhookURL, message, nil); err != nil {
+			return app.PublishResult{}, errors.WithMessagef(err, "Cannot post release %s to %s", note.Tag, p.name)
+		}
+	}
+	return app.PublishResult{Status: app.PublishSent}, nil
+}
+
+// chatFormat converts the Markdown of a note to the markup of a chat.
+type chatFormat struct {
+	heading    func(title string) string
+	subheading func(title string) string
+	// text converts a Markdown line, escaping and rewriting its links.
+	text func(markdown string) string
+}
+
+// noteItems flattens the summary and sections of a note into items that are
+// never split across messages, a merge request stays with its commits.
+func noteItems(note app.ReleaseNote, format chatFormat) []string {
+	var items []string
+	if len(note.Summary) > 0 {
+		items = append(items, format.heading("Summary"))
+		for _, line := range note.Summary {
+			items = append(items, format.text("- "+line))
+		}
+	}
+
+	for _, section := range note.Sections {
+		items = append(items, format.heading(section.Title))
+		if len(section.Groups) == 0 {
+			items = append(items, entryItems(section.Entries, format)...)
+			continue
+		}
+
+		for _, group := range section.Groups {
+			items = append(items, format.subheading(group.Title))
+			items = append(items, entryItems(group.Entries, format)...)
+		}
+	}
+	return items
+}
+
+func entryItems(entries []app.Entry, format chatFormat) []string {
+	items := make([]string, len(entries))
+	for i, entry := range entries {
+		items[i] = format.text(entry.Message)
+	}
+	return items
+}
+
+// packItems joins items with new lines into chunks of at most limit
+// characters. An item longer than limit is truncated.
+func packItems(items []string, limit int) []string {
+	var chunks []string
+	current := ""
+	for _, item := range items {
+		item = truncateText(item, limit)
+		if current != "" && length(current)+1+length(item) > limit {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n"
+		}
+		current += item
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// fitMessages packs items into the texts of messages of at most limit
+// characters. When truncating, only the first message is kept and it ends
+// with more if anything was left out.
+func fitMessages(items []string, limit int, overflow, more string) []string {
+	chunks := packItems(items, limit)
+	if overflow != OverflowTruncate || len(chunks) <= 1 {
+		return chunks
+	}
+
+	chunks = packItems(items, limit-length(more)-1)
+	return []string{chunks[0] + "\n" + more}
+}
+
+func truncateText(text string, limit int) string {
+	if length(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
+
+func length(text string) int {
+	return utf8.RuneCountInString(text)
+}
+
+// noteTitle returns the title of the messages of a note.
+func noteTitle(note app.ReleaseNote) string {
+	return fmt.Sprintf("Release %s (%s)", note.Tag, note.DateString())
+}
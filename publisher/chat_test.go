@@ -0,0 +1,109 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitLab-rls-note/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNote(entries int) app.ReleaseNote {
+	note := app.ReleaseNote{
+		Tag:  "v1.2.0",
+		Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		URL:  "https://gitlab.example.com/grp/proj/-/releases/v1.2.0",
+	}
+	section := app.NoteSection{Name: "feature", Title: "New features"}
+	for i := 1; i <= entries; i++ {
+		section.Entries = append(section.Entries, app.Entry{
+			Message: fmt.Sprintf("- Feature %d & more [#%d](https://gitlab.example.com/mr/%d)", i, i, i),
+		})
+	}
+	note.Sections = []app.NoteSection{section}
+	return note
+}
+
+func TestSlackMessages_ConvertsMarkdown(t *testing.T) {
+	messages := slackMessages(testNote(1), OverflowSplit)
+
+	assert.Len(t, messages, 1)
+	message := messages[0].(slackMessage)
+	assert.Equal(t, "Release v1.2.0 (2024-02-01)", message.Blocks[0].Text.Text)
+	assert.Equal(t, "*New features*\n- Feature 1 &amp; more <https://gitlab.example.com/mr/1|#1>", message.Blocks[1].Text.Text)
+}
+
+func TestSlackMessages_Overflow(t *testing.T) {
+	// Every entry is long enough to fill a section block on its own.
+	note := testNote(0)
+	for i := 0; i < 120; i++ {
+		note.Sections[0].Entries = append(note.Sections[0].Entries, app.Entry{Message: strings.Repeat("x", 2000)})
+	}
+
+	split := slackMessages(note, OverflowSplit)
+	assert.Len(t, split, 3)
+	for _, message := range split {
+		assert.LessOrEqual(t, len(message.(slackMessage).Blocks), slackMaxBlocks)
+	}
+
+	truncated := slackMessages(note, OverflowTruncate)
+	assert.Len(t, truncated, 1)
+	blocks := truncated[0].(slackMessage).Blocks
+	assert.Len(t, blocks, slackMaxBlocks)
+	assert.Contains(t, blocks[len(blocks)-1].Text.Text, "<"+note.URL+"|"+fullReleaseText+">")
+}
+
+func TestFitMessages(t *testing.T) {
+	items := []string{"aaaa", "bbbb", "cccc"}
+
+	assert.Equal(t, []string{"aaaa\nbbbb", "cccc"}, fitMessages(items, 9, OverflowSplit, "more"))
+	assert.Equal(t, []string{"aaaa\nmore"}, fitMessages(items, 9, OverflowTruncate, "more"))
+	assert.Equal(t, []string{"aaaa\nbbbb\ncccc"}, fitMessages(items, 20, OverflowTruncate, "more"))
+	assert.Equal(t, []string{"aaa…"}, fitMessages([]string{"aaaaaa"}, 4, OverflowSplit, "more"))
+}
+
+func TestChatPublisher_Publish(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	chat, err := NewMattermostPublisher(ChatConfig{WebhookURL: server.URL + "/hooks/secret"})
+	assert.NoError(t, err)
+	result, err := chat.Publish(testNote(2))
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishSent, result.Status)
+
+	assert.Len(t, bodies, 1)
+	var message mattermostMessage
+	assert.NoError(t, json.Unmarshal([]byte(bodies[0]), &message))
+	assert.Equal(t, "### Release [v1.2.0](https://gitlab.example.com/grp/proj/-/releases/v1.2.0) (2024-02-01)\n"+
+		"#### New features\n"+
+		"- Feature 1 & more [#1](https://gitlab.example.com/mr/1)\n"+
+		"- Feature 2 & more [#2](https://gitlab.example.com/mr/2)", message.Text)
+}
+
+func TestChatPublisher_PublishFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("invalid_blocks"))
+	}))
+	defer server.Close()
+
+	chat, err := NewSlackPublisher(ChatConfig{WebhookURL: server.URL + "/services/secret"})
+	assert.NoError(t, err)
+
+	_, err = chat.Publish(testNote(1))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "responded 400: invalid_blocks")
+	assert.NotContains(t, err.Error(), "secret")
+}
@@ -0,0 +1,255 @@
+package publisher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	// SMTPStartTLS upgrades the connection with STARTTLS and fails when the
+	// server does not support it.
+	SMTPStartTLS = "starttls"
+	// SMTPPlain sends the email over an unencrypted connection.
+	SMTPPlain = "none"
+
+	emailName        = "email"
+	defaultSMTPPort  = 587
+	smtpDialTimeout  = 30 * time.Second
+	emailLineBreak   = "\r\n"
+	messageIDPrefix  = "rlsnote."
+	htmlEmailStyle   = "font-family: sans-serif;"
+	emailContentType = "multipart/alternative"
+)
+
+// EmailConfig configures the email publisher.
+type EmailConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth when Username is set.
+	Username string
+	Password string
+	// TLS is SMTPStartTLS or SMTPPlain, SMTPStartTLS by default.
+	TLS       string
+	TLSConfig *tls.Config
+	From      string
+	To        []string
+	// SubjectPrefix is prepended to the subject, eg: [my-project].
+	SubjectPrefix string
+}
+
+// EmailPublisher sends release notes as HTML and plain text emails.
+type EmailPublisher struct {
+	config EmailConfig
+	from   *mail.Address
+	to     []*mail.Address
+}
+
+func NewEmailPublisher(config EmailConfig) (*EmailPublisher, error) {
+	if config.Host == "" {
+		return nil, errors.New("Missing SMTP host.")
+	}
+	if config.Port == 0 {
+		config.Port = defaultSMTPPort
+	}
+	switch config.TLS {
+	case "":
+		config.TLS = SMTPStartTLS
+	case SMTPStartTLS, SMTPPlain:
+	default:
+		return nil, errors.Errorf("Unsupported SMTP TLS mode: %s", config.TLS)
+	}
+	if config.TLSConfig == nil {
+		config.TLSConfig = &tls.Config{ServerName: config.Host}
+	}
+
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid email sender %q", config.From)
+	}
+	if len(config.To) == 0 {
+		return nil, errors.New("Missing email recipients.")
+	}
+	to := make([]*mail.Address, len(config.To))
+	for i, recipient := range config.To {
+		if to[i], err = mail.ParseAddress(recipient); err != nil {
+			return nil, errors.Wrapf(err, "Invalid email recipient %q", recipient)
+		}
+	}
+	return &EmailPublisher{config: config, from: from, to: to}, nil
+}
+
+func (p *EmailPublisher) Name() string {
+	return emailName
+}
+
+// Announces returns true, the email is not sent again on reruns.
+func (p *EmailPublisher) Announces() bool {
+	return true
+}
+
+// Publish sends a single email to all recipients.
+func (p *EmailPublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	message, err := p.buildMessage(note, time.Now())
+	if err != nil {
+		return app.PublishResult{}, err
+	}
+	if err := p.send(message); err != nil {
+		return app.PublishResult{}, errors.WithMessagef(err, "Cannot email release %s", note.Tag)
+	}
+	return app.PublishResult{Status: app.PublishSent}, nil
+}
+
+func (p *EmailPublisher) send(message []byte) error {
+	addr := net.JoinHostPort(p.config.Host, strconv.Itoa(p.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, smtpDialTimeout)
+	if err != nil {
+		return errors.WithTemporary(err, unavailableCode)
+	}
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		conn.Close()
+		return errors.WithStack(err)
+	}
+	defer client.Close()
+
+	if p.config.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.Errorf("SMTP server %s does not support STARTTLS.", addr)
+		}
+		if err := client.StartTLS(p.config.TLSConfig); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if p.config.Username != "" {
+		auth := smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return errors.WithCode(errors.WithStack(err), "smtp_unauthorized")
+		}
+	}
+
+	if err := client.Mail(p.from.Address); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, to := range p.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(client.Quit())
+}
+
+// buildMessage renders the note as a multipart/alternative message with a
+// plain text part, the Markdown note, and an HTML part.
+func (p *EmailPublisher) buildMessage(note app.ReleaseNote, date time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", emailText(note)},
+		{"text/html; charset=utf-8", emailHTML(note)},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	to := make([]string, len(p.to))
+	for i, address := range p.to {
+		to[i] = address.String()
+	}
+	subject := noteTitle(note)
+	if p.config.SubjectPrefix != "" {
+		subject = p.config.SubjectPrefix + " " + subject
+	}
+
+	var message bytes.Buffer
+	for _, header := range [][2]string{
+		{"From", p.from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID(p.from.Address)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType(emailContentType, map[string]string{"boundary": parts.Boundary()})},
+	} {
+		message.WriteString(header[0] + ": " + header[1] + emailLineBreak)
+	}
+	message.WriteString(emailLineBreak)
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+func emailText(note app.ReleaseNote) string {
+	text := note.Markdown()
+	if note.URL != "" {
+		text += "\n" + fullReleaseText + ": " + note.URL + "\n"
+	}
+	return text
+}
+
+func emailHTML(note app.ReleaseNote) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n", html.EscapeString(noteTitle(note)))
+	fmt.Fprintf(&out, "<body style=\"%s\">\n", htmlEmailStyle)
+	out.WriteString(note.HTML())
+	if note.URL != "" {
+		fmt.Fprintf(&out, "<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(note.URL), fullReleaseText)
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+func messageID(from string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	return "<" + messageIDPrefix + hex.EncodeToString(b) + "@" + domain + ">"
+}
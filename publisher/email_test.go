@@ -0,0 +1,201 @@
+package publisher
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitLab-rls-note/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSMTP is a minimal SMTP server recording the emails it receives,
+// supporting STARTTLS when it has a TLS config.
+type fakeSMTP struct {
+	mu        sync.Mutex
+	tlsConfig *tls.Config
+	mails     []fakeMail
+}
+
+type fakeMail struct {
+	auth   string
+	secure bool
+	from   string
+	to     []string
+	data   string
+}
+
+func newFakeSMTP(t *testing.T, tlsConfig *tls.Config) (*fakeSMTP, int) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{tlsConfig: tlsConfig}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, listener.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+
+	var mail fakeMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			_ = tp.PrintfLine("250-fake")
+			if f.tlsConfig != nil && !mail.secure {
+				_ = tp.PrintfLine("250-STARTTLS")
+			}
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 Ready to start TLS")
+			conn = tls.Server(conn, f.tlsConfig)
+			tp = textproto.NewConn(conn)
+			mail.secure = true
+		case "AUTH":
+			mail.auth = arg
+			_ = tp.PrintfLine("235 Authenticated")
+		case "MAIL":
+			mail.from = arg
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, arg)
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			data, _ := tp.ReadDotBytes()
+			mail.data = string(data)
+			f.mu.Lock()
+			f.mails = append(f.mails, mail)
+			f.mu.Unlock()
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestEmailPublisher_Publish(t *testing.T) {
+	server, port := newFakeSMTP(t, nil)
+	email, err := NewEmailPublisher(EmailConfig{
+		Host:          "127.0.0.1",
+		Port:          port,
+		Username:      "bot",
+		Password:      "pass",
+		TLS:           SMTPPlain,
+		From:          "Releases <releases@example.com>",
+		To:            []string{"dev@example.com", "Ops <ops@example.com>"},
+		SubjectPrefix: "[proj]",
+	})
+	assert.NoError(t, err)
+
+	result, err := email.Publish(testNote(2))
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishSent, result.Status)
+
+	assert.Len(t, server.mails, 1)
+	sent := server.mails[0]
+	assert.Equal(t, "PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00bot\x00pass")), sent.auth)
+	assert.Equal(t, "FROM:<releases@example.com>", sent.from)
+	assert.Equal(t, []string{"TO:<dev@example.com>", "TO:<ops@example.com>"}, sent.to)
+
+	msg, err := mail.ReadMessage(strings.NewReader(sent.data))
+	assert.NoError(t, err)
+	assert.Equal(t, "[proj] Release v1.2.0 (2024-02-01)", msg.Header.Get("Subject"))
+	assert.Equal(t, `"Releases" <releases@example.com>`, msg.Header.Get("From"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := parts.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", text.Header.Get("Content-Type"))
+	content, _ := io.ReadAll(text)
+	assert.Contains(t, string(content), "- Feature 1 & more [#1](https://gitlab.example.com/mr/1)\n")
+
+	htmlPart, err := parts.NextPart()
+	assert.NoError(t, err)
+	assert.Equal(t, "text/html; charset=utf-8", htmlPart.Header.Get("Content-Type"))
+	content, _ = io.ReadAll(htmlPart)
+	assert.Contains(t, string(content), `<li>Feature 1 &amp; more <a href="https://gitlab.example.com/mr/1">#1</a></li>`)
+	assert.Contains(t, string(content), `<a href="https://gitlab.example.com/grp/proj/-/releases/v1.2.0">`)
+}
+
+func TestEmailPublisher_StartTLS(t *testing.T) {
+	// Reuse the self-signed certificate of httptest, valid for 127.0.0.1.
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+	clientTLS := ts.Client().Transport.(*http.Transport).TLSClientConfig
+
+	server, port := newFakeSMTP(t, &tls.Config{Certificates: ts.TLS.Certificates})
+	email, err := NewEmailPublisher(EmailConfig{
+		Host:      "127.0.0.1",
+		Port:      port,
+		TLSConfig: &tls.Config{RootCAs: clientTLS.RootCAs, ServerName: "127.0.0.1"},
+		From:      "releases@example.com",
+		To:        []string{"dev@example.com"},
+	})
+	assert.NoError(t, err)
+
+	result, err := email.Publish(testNote(1))
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishSent, result.Status)
+	assert.Len(t, server.mails, 1)
+	assert.True(t, server.mails[0].secure)
+}
+
+func TestEmailPublisher_RequiresStartTLS(t *testing.T) {
+	server, port := newFakeSMTP(t, nil)
+	email, err := NewEmailPublisher(EmailConfig{
+		Host: "127.0.0.1",
+		Port: port,
+		From: "releases@example.com",
+		To:   []string{"dev@example.com"},
+	})
+	assert.NoError(t, err)
+
+	_, err = email.Publish(testNote(1))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "127.0.0.1:"+strconv.Itoa(port)+" does not support STARTTLS")
+	assert.Empty(t, server.mails)
+}
+
+func TestNewEmailPublisher_InvalidAddress(t *testing.T) {
+	_, err := NewEmailPublisher(EmailConfig{Host: "smtp.example.com", From: "releases", To: []string{"dev@example.com"}})
+	assert.Error(t, err)
+
+	_, err = NewEmailPublisher(EmailConfig{Host: "smtp.example.com", From: "releases@example.com", To: []string{"dev@"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package publisher
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	fileName   = "file"
+	stdoutName = "stdout"
+	// tagPlaceholder is replaced by the tag name in file paths.
+	tagPlaceholder = "{tag}"
+)
+
+// FilePublisher writes the rendered note to a file.
+type FilePublisher struct {
+	path   string
+	format string
+}
+
+// NewFilePublisher writes the note to path, where {tag} is replaced by the
+// tag name, eg: release-notes/{tag}.md.
+func NewFilePublisher(path, format string) (*FilePublisher, error) {
+	if path == "" {
+		return nil, errors.New("Missing file path.")
+	}
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	return &FilePublisher{path, format}, nil
+}
+
+func (p *FilePublisher) Name() string {
+	return fileName
+}
+
+// Publish writes the file unless it already has the same content.
+func (p *FilePublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	content, err := Render(note, p.format)
+	if err != nil {
+		return app.PublishResult{}, err
+	}
+
+	// Tags like release/2024.1 must not create directories.
+	path := strings.ReplaceAll(p.path, tagPlaceholder, strings.ReplaceAll(note.Tag, "/", "-"))
+	result := app.PublishResult{Status: app.PublishCreated, Description: string(content)}
+	previous, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		result.PreviousDescription = string(previous)
+		if bytes.Equal(previous, content) {
+			result.Status = app.PublishUnchanged
+			return result, nil
+		}
+		result.Status = app.PublishUpdated
+	case !os.IsNotExist(err):
+		return app.PublishResult{}, errors.WithStack(err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return app.PublishResult{}, errors.WithStack(err)
+		}
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return app.PublishResult{}, errors.WithStack(err)
+	}
+	return result, nil
+}
+
+// WriterPublisher writes the rendered note to a writer, eg: the standard output.
+type WriterPublisher struct {
+	name   string
+	w      io.Writer
+	format string
+}
+
+func NewStdoutPublisher(format string) (*WriterPublisher, error) {
+	return NewWriterPublisher(stdoutName, os.Stdout, format)
+}
+
+func NewWriterPublisher(name string, w io.Writer, format string) (*WriterPublisher, error) {
+	if err := ValidateFormat(format); err != nil {
+		return nil, err
+	}
+	return &WriterPublisher{name, w, format}, nil
+}
+
+func (p *WriterPublisher) Name() string {
+	return p.name
+}
+
+func (p *WriterPublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	content, err := Render(note, p.format)
+	if err != nil {
+		return app.PublishResult{}, err
+	}
+	if _, err := p.w.Write(content); err != nil {
+		return app.PublishResult{}, errors.WithStack(err)
+	}
+	return app.PublishResult{Status: app.PublishSent, Description: string(content)}, nil
+}
@@ -0,0 +1,42 @@
+package publisher
+
+import (
+	"encoding/json"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	// FormatJSON is the payload of the webhook publisher.
+	FormatJSON = "json"
+)
+
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatMarkdown, FormatHTML, FormatJSON:
+		return nil
+	default:
+		return errors.Errorf("Unsupported format: %s", format)
+	}
+}
+
+// Render renders the note in the format, Markdown by default.
+func Render(note app.ReleaseNote, format string) ([]byte, error) {
+	switch format {
+	case "", FormatMarkdown:
+		return []byte(note.Markdown()), nil
+	case FormatHTML:
+		return []byte(note.HTML()), nil
+	case FormatJSON:
+		body, err := json.MarshalIndent(NewWebhookPayload(note), "", "  ")
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return append(body, '\n'), nil
+	default:
+		return nil, errors.Errorf("Unsupported format: %s", format)
+	}
+}
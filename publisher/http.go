@@ -0,0 +1,70 @@
+package publisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	defaultTimeout         = 30 * time.Second
+	maxErrorResponseLength = 200
+
+	unavailableCode   = "publisher_unavailable"
+	requestFailedCode = "publisher_request_failed"
+)
+
+// postJSON posts payload as JSON to url and fails on non 2xx responses.
+func postJSON(client *http.Client, url string, payload interface{}, header http.Header) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return post(client, url, body, header)
+}
+
+func post(client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.WithTemporary(err, unavailableCode)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		responseBody, _ := io.ReadAll(resp.Body)
+		message := strings.TrimSpace(string(responseBody))
+		if len(message) > maxErrorResponseLength {
+			message = message[:maxErrorResponseLength] + "..."
+		}
+		err := errors.Errorf("POST %s responded %d: %s", redactURL(url), resp.StatusCode, message)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return errors.WithTemporary(err, unavailableCode)
+		}
+		return errors.WithCode(err, requestFailedCode)
+	}
+	return nil
+}
+
+// redactURL hides the path of webhook URLs since it usually holds a secret.
+func redactURL(rawURL string) string {
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		if j := strings.Index(rawURL[i+3:], "/"); j >= 0 {
+			return rawURL[:i+3+j] + "/***"
+		}
+	}
+	return rawURL
+}
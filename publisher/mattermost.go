@@ -0,0 +1,47 @@
+package publisher
+
+import (
+	"fmt"
+
+	"gitLab-rls-note/app"
+)
+
+const (
+	mattermostName          = "Mattermost"
+	mattermostMaxTextLength = 16383
+)
+
+type mattermostMessage struct {
+	Text string `json:"text"`
+}
+
+// NewMattermostPublisher posts release notes as Markdown messages to a
+// Mattermost incoming webhook.
+func NewMattermostPublisher(config ChatConfig) (*ChatPublisher, error) {
+	return newChatPublisher(mattermostName, config, mattermostMessages)
+}
+
+var mattermostFormat = chatFormat{
+	heading:    func(title string) string { return "#### " + title },
+	subheading: func(title string) string { return "##### " + title },
+	text:       func(markdown string) string { return markdown },
+}
+
+func mattermostMessages(note app.ReleaseNote, overflow string) []interface{} {
+	title := "### " + noteTitle(note)
+	if note.URL != "" {
+		title = fmt.Sprintf("### Release [%s](%s) (%s)", note.Tag, note.URL, note.DateString())
+	}
+	items := append([]string{title}, noteItems(note, mattermostFormat)...)
+
+	more := "…\n" + fullReleaseText
+	if note.URL != "" {
+		more = fmt.Sprintf("…\n[%s](%s)", fullReleaseText, note.URL)
+	}
+
+	var messages []interface{}
+	for _, text := range fitMessages(items, mattermostMaxTextLength, overflow, more) {
+		messages = append(messages, mattermostMessage{Text: text})
+	}
+	return messages
+}
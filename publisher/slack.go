@@ -0,0 +1,78 @@
+package publisher
+
+import (
+	"strings"
+
+	"gitLab-rls-note/app"
+)
+
+const (
+	slackName             = "Slack"
+	slackMaxBlocks        = 50
+	slackMaxSectionLength = 3000
+	slackMaxHeaderLength  = 150
+)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewSlackPublisher posts release notes as Block Kit messages to a Slack
+// incoming webhook.
+func NewSlackPublisher(config ChatConfig) (*ChatPublisher, error) {
+	return newChatPublisher(slackName, config, slackMessages)
+}
+
+var slackFormat = chatFormat{
+	heading:    func(title string) string { return "*" + slackEscaper.Replace(title) + "*" },
+	subheading: func(title string) string { return "_" + slackEscaper.Replace(title) + "_" },
+	text: func(markdown string) string {
+		// Links are escaped as a whole since "&" is valid in their URLs.
+		return markdownLinkRegex.ReplaceAllStringFunc(slackEscaper.Replace(markdown), func(link string) string {
+			match := markdownLinkRegex.FindStringSubmatch(link)
+			return "<" + match[2] + "|" + match[1] + ">"
+		})
+	},
+}
+
+// slackMessages converts a note to messages of sections blocks, a message
+// holds at most slackMaxBlocks blocks of slackMaxSectionLength characters.
+func slackMessages(note app.ReleaseNote, overflow string) []interface{} {
+	title := noteTitle(note)
+	header := slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: truncateText(title, slackMaxHeaderLength)}}
+	sections := packItems(noteItems(note, slackFormat), slackMaxSectionLength)
+
+	// The first message starts with the header block.
+	capacity := slackMaxBlocks - 1
+	if overflow == OverflowTruncate && len(sections) > capacity {
+		more := fullReleaseText
+		if note.URL != "" {
+			more = "<" + note.URL + "|" + fullReleaseText + ">"
+		}
+		sections = append(sections[:capacity-1], "…\n"+more)
+	}
+
+	var messages []interface{}
+	blocks := []slackBlock{header}
+	for _, section := range sections {
+		if len(blocks) == slackMaxBlocks {
+			messages = append(messages, slackMessage{Text: title, Blocks: blocks})
+			blocks = nil
+		}
+		blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: section}})
+	}
+	return append(messages, slackMessage{Text: title, Blocks: blocks})
+}
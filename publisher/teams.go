@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"fmt"
+	"strings"
+
+	"gitLab-rls-note/app"
+)
+
+const (
+	teamsName = "Teams"
+	// teamsMaxTextLength keeps the cards below the 28 KB limit of Teams
+	// messages, leaving room for the doubled new lines and JSON escaping.
+	teamsMaxTextLength = 9000
+)
+
+type teamsMessageCard struct {
+	Type            string        `json:"@type"`
+	Context         string        `json:"@context"`
+	Summary         string        `json:"summary"`
+	Title           string        `json:"title"`
+	Text            string        `json:"text"`
+	PotentialAction []teamsAction `json:"potentialAction,omitempty"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// NewTeamsPublisher posts release notes as message cards to a Microsoft Teams
+// incoming webhook.
+func NewTeamsPublisher(config ChatConfig) (*ChatPublisher, error) {
+	return newChatPublisher(teamsName, config, teamsMessages)
+}
+
+var teamsFormat = chatFormat{
+	heading:    func(title string) string { return "**" + title + "**" },
+	subheading: func(title string) string { return "*" + title + "*" },
+	text:       func(markdown string) string { return markdown },
+}
+
+func teamsMessages(note app.ReleaseNote, overflow string) []interface{} {
+	more := "…"
+	var actions []teamsAction
+	if note.URL != "" {
+		more = fmt.Sprintf("…\n[%s](%s)", fullReleaseText, note.URL)
+		actions = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "View release",
+			Targets: []teamsTarget{{OS: "default", URI: note.URL}},
+		}}
+	}
+
+	texts := fitMessages(noteItems(note, teamsFormat), teamsMaxTextLength, overflow, more)
+	if len(texts) == 0 {
+		texts = []string{""}
+	}
+	title := noteTitle(note)
+	var messages []interface{}
+	for i, text := range texts {
+		cardTitle := title
+		if len(texts) > 1 {
+			cardTitle = fmt.Sprintf("%s %d/%d", title, i+1, len(texts))
+		}
+		messages = append(messages, teamsMessageCard{
+			Type:    "MessageCard",
+			Context: "https://schema.org/extensions",
+			Summary: title,
+			Title:   cardTitle,
+			// Teams only breaks lines of Markdown text between paragraphs.
+			Text:            strings.ReplaceAll(text, "\n", "\n\n"),
+			PotentialAction: actions,
+		})
+	}
+	return messages
+}
@@ -0,0 +1,209 @@
+package publisher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	// SignatureHeader holds the HMAC-SHA256 of the body keyed by the shared
+	// secret, eg: sha256=5d61605c3feea9799210ddcb71307d4ba264225f.
+	SignatureHeader = "X-Rlsnote-Signature"
+	// EventHeader holds the event of the payload.
+	EventHeader = "X-Rlsnote-Event"
+
+	ReleaseEvent = "release"
+
+	webhookName           = "webhook"
+	signaturePrefix       = "sha256="
+	defaultWebhookRetries = 3
+	defaultRetryDelay     = time.Second
+)
+
+// WebhookConfig configures the webhook publisher.
+type WebhookConfig struct {
+	URLs []string
+	// Secret signs the payloads, they are not signed when it is empty.
+	Secret string
+	// Retries is the number of retries of a failed delivery, a negative
+	// value disables them.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled after every retry.
+	RetryDelay time.Duration
+	HTTPClient *http.Client
+}
+
+// WebhookPublisher posts the release note as JSON to every configured URL.
+type WebhookPublisher struct {
+	config WebhookConfig
+}
+
+// WebhookPayload is the JSON body posted by the webhook publisher.
+type WebhookPayload struct {
+	Event       string           `json:"event"`
+	Tag         string           `json:"tag"`
+	PreviousTag string           `json:"previous_tag"`
+	Date        time.Time        `json:"date"`
+	URL         string           `json:"url,omitempty"`
+	Summary     []string         `json:"summary,omitempty"`
+	Sections    []WebhookSection `json:"sections"`
+	Milestones  []string         `json:"milestones,omitempty"`
+	Markdown    string           `json:"markdown"`
+}
+
+type WebhookSection struct {
+	Name    string         `json:"name"`
+	Title   string         `json:"title"`
+	Entries []WebhookEntry `json:"entries"`
+	Groups  []WebhookGroup `json:"groups,omitempty"`
+}
+
+type WebhookGroup struct {
+	Key     string         `json:"key"`
+	Title   string         `json:"title"`
+	Entries []WebhookEntry `json:"entries"`
+}
+
+type WebhookEntry struct {
+	// Kind is merge_request or issue.
+	Kind       string    `json:"kind"`
+	IID        int       `json:"iid"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	Author     string    `json:"author,omitempty"`
+	Date       time.Time `json:"date"`
+	Labels     []string  `json:"labels,omitempty"`
+	Milestone  string    `json:"milestone,omitempty"`
+	Components []string  `json:"components,omitempty"`
+}
+
+func NewWebhookPublisher(config WebhookConfig) (*WebhookPublisher, error) {
+	if len(config.URLs) == 0 {
+		return nil, errors.New("Missing webhook URLs.")
+	}
+	for _, rawURL := range config.URLs {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.Errorf("Invalid webhook URL: %s", redactURL(rawURL))
+		}
+	}
+
+	if config.Retries == 0 {
+		config.Retries = defaultWebhookRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+	return &WebhookPublisher{config}, nil
+}
+
+func (p *WebhookPublisher) Name() string {
+	return webhookName
+}
+
+// Announces returns true, the webhooks are not delivered again on reruns.
+func (p *WebhookPublisher) Announces() bool {
+	return true
+}
+
+// Publish delivers the note to every URL, a failing URL does not prevent the
+// delivery to the others.
+func (p *WebhookPublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	body, err := json.Marshal(NewWebhookPayload(note))
+	if err != nil {
+		return app.PublishResult{}, errors.WithStack(err)
+	}
+
+	header := http.Header{}
+	header.Set(EventHeader, ReleaseEvent)
+	if p.config.Secret != "" {
+		header.Set(SignatureHeader, Sign(p.config.Secret, body))
+	}
+
+	var firstErr error
+	for _, rawURL := range p.config.URLs {
+		if err := p.deliver(rawURL, body, header); err != nil && firstErr == nil {
+			firstErr = errors.WithMessagef(err, "Cannot deliver release %s", note.Tag)
+		}
+	}
+	if firstErr != nil {
+		return app.PublishResult{}, firstErr
+	}
+	return app.PublishResult{Status: app.PublishSent}, nil
+}
+
+// deliver posts body, retrying on network errors, 429 and 5xx responses.
+func (p *WebhookPublisher) deliver(rawURL string, body []byte, header http.Header) error {
+	delay := p.config.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := post(p.config.HTTPClient, rawURL, body, header)
+		if err == nil || !errors.IsTemporary(err) || attempt >= p.config.Retries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Sign returns the signature of body, to be compared by receivers with the
+// SignatureHeader using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewWebhookPayload(note app.ReleaseNote) WebhookPayload {
+	payload := WebhookPayload{
+		Event:       ReleaseEvent,
+		Tag:         note.Tag,
+		PreviousTag: note.PreviousTag,
+		Date:        note.Date,
+		URL:         note.URL,
+		Summary:     note.Summary,
+		Sections:    []WebhookSection{},
+		Milestones:  note.Milestones,
+		Markdown:    note.Markdown(),
+	}
+	for _, section := range note.Sections {
+		s := WebhookSection{Name: section.Name, Title: section.Title, Entries: webhookEntries(section.Entries)}
+		for _, group := range section.Groups {
+			s.Groups = append(s.Groups, WebhookGroup{Key: group.Key, Title: group.Title, Entries: webhookEntries(group.Entries)})
+		}
+		payload.Sections = append(payload.Sections, s)
+	}
+	return payload
+}
+
+func webhookEntries(entries []app.Entry) []WebhookEntry {
+	result := make([]WebhookEntry, len(entries))
+	for i, entry := range entries {
+		kind := "merge_request"
+		if entry.DefaultLabel == "issues" {
+			kind = "issue"
+		}
+		result[i] = WebhookEntry{
+			Kind:       kind,
+			IID:        entry.IID,
+			Title:      entry.Title,
+			URL:        entry.URL,
+			Author:     entry.Author,
+			Date:       entry.Date,
+			Labels:     entry.Labels,
+			Milestone:  entry.Milestone,
+			Components: entry.Components,
+		}
+	}
+	return result
+}
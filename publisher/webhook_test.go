@@ -0,0 +1,117 @@
+package publisher
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gitLab-rls-note/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// webhookReceiver answers with the given statuses in turn, then 200, and
+// records the deliveries.
+type webhookReceiver struct {
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []delivery
+}
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, string) {
+	r := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.deliveries = append(r.deliveries, delivery{req.Header, body})
+		if len(r.statuses) > 0 {
+			w.WriteHeader(r.statuses[0])
+			r.statuses = r.statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+	return r, server.URL + "/hooks/release"
+}
+
+func TestWebhookPublisher_SignsPayload(t *testing.T) {
+	receiver, url := newWebhookReceiver(t)
+	webhook, err := NewWebhookPublisher(WebhookConfig{URLs: []string{url}, Secret: "s3cret"})
+	assert.NoError(t, err)
+
+	result, err := webhook.Publish(testNote(2))
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishSent, result.Status)
+
+	assert.Len(t, receiver.deliveries, 1)
+	d := receiver.deliveries[0]
+	assert.Equal(t, "application/json", d.header.Get("Content-Type"))
+	assert.Equal(t, ReleaseEvent, d.header.Get(EventHeader))
+	assert.True(t, hmac.Equal([]byte(Sign("s3cret", d.body)), []byte(d.header.Get(SignatureHeader))))
+	assert.False(t, hmac.Equal([]byte(Sign("other", d.body)), []byte(d.header.Get(SignatureHeader))))
+
+	var payload WebhookPayload
+	assert.NoError(t, json.Unmarshal(d.body, &payload))
+	assert.Equal(t, "v1.2.0", payload.Tag)
+	assert.Equal(t, "New features", payload.Sections[0].Title)
+	assert.Len(t, payload.Sections[0].Entries, 2)
+	assert.Equal(t, "merge_request", payload.Sections[0].Entries[0].Kind)
+	assert.Contains(t, payload.Markdown, "#### New features\n")
+}
+
+func TestWebhookPublisher_Retries(t *testing.T) {
+	tcs := []struct {
+		name       string
+		statuses   []int
+		deliveries int
+		failed     bool
+	}{
+		{"recovers", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, false},
+		{"gives up", []int{500, 502, 503, 504}, 3, true},
+		{"client error", []int{http.StatusBadRequest}, 1, true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver, url := newWebhookReceiver(t, tc.statuses...)
+			webhook, err := NewWebhookPublisher(WebhookConfig{URLs: []string{url}, Retries: 2, RetryDelay: time.Millisecond})
+			assert.NoError(t, err)
+
+			_, err = webhook.Publish(testNote(1))
+			assert.Equal(t, tc.failed, err != nil)
+			assert.Len(t, receiver.deliveries, tc.deliveries)
+			assert.Empty(t, receiver.deliveries[0].header.Get(SignatureHeader))
+		})
+	}
+}
+
+func TestWebhookPublisher_DeliversToEveryURL(t *testing.T) {
+	failing, failingURL := newWebhookReceiver(t, http.StatusNotFound)
+	working, workingURL := newWebhookReceiver(t)
+	webhook, err := NewWebhookPublisher(WebhookConfig{URLs: []string{failingURL, workingURL}})
+	assert.NoError(t, err)
+
+	_, err = webhook.Publish(testNote(1))
+	assert.Error(t, err)
+	assert.Len(t, failing.deliveries, 1)
+	assert.Len(t, working.deliveries, 1)
+}
+
+func TestNewWebhookPublisher_InvalidURL(t *testing.T) {
+	_, err := NewWebhookPublisher(WebhookConfig{URLs: []string{"ftp://example.com/hook"}})
+	assert.Error(t, err)
+
+	_, err = NewWebhookPublisher(WebhookConfig{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package publisher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	wikiName          = "wiki"
+	wikiFormat        = "markdown"
+	defaultWikiPrefix = "Releases"
+)
+
+// wikiIndexEntryRegex matches the entries of the index page, eg:
+// - [v1.2.3](Releases/v1.2.3) (2024-02-01)
+var wikiIndexEntryRegex = regexp.MustCompile(`^- \[(.+)\]\((\S+)\) \((\d{4}-\d{2}-\d{2})\)$`)
+
+// WikiClient is the part of the GitLab client managing wiki pages.
+type WikiClient interface {
+	RetrieveWikiPage(slug string) (app.WikiPage, error)
+	CreateWikiPage(page app.WikiPage) error
+	UpdateWikiPage(slug string, page app.WikiPage) error
+}
+
+type WikiConfig struct {
+	// Prefix is the title of the index page and the parent of the release
+	// pages, Releases by default.
+	Prefix string
+}
+
+// WikiPublisher writes a wiki page per release and an index page listing
+// the releases newest-first.
+type WikiPublisher struct {
+	client WikiClient
+	config WikiConfig
+}
+
+type wikiIndexEntry struct {
+	tag  string
+	link string
+	date string
+}
+
+func NewWikiPublisher(client WikiClient, config WikiConfig) *WikiPublisher {
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	if config.Prefix == "" {
+		config.Prefix = defaultWikiPrefix
+	}
+	return &WikiPublisher{client, config}
+}
+
+func (p *WikiPublisher) Name() string {
+	return wikiName
+}
+
+// Publish saves the page of the release and the index page, the result is
+// the one of the release page.
+func (p *WikiPublisher) Publish(note app.ReleaseNote) (app.PublishResult, error) {
+	title := p.config.Prefix + "/" + note.Tag
+	content := note.Markdown()
+	if note.URL != "" {
+		content += fmt.Sprintf("\n[%s](%s)\n", fullReleaseText, note.URL)
+	}
+	result, err := p.savePage(title, func(string) string { return content })
+	if err != nil {
+		return app.PublishResult{}, errors.WithMessagef(err, "Cannot save wiki page of release %s", note.Tag)
+	}
+
+	entry := wikiIndexEntry{tag: note.Tag, link: wikiSlug(title), date: note.DateString()}
+	if _, err := p.savePage(p.config.Prefix, func(current string) string { return p.indexContent(current, entry) }); err != nil {
+		return app.PublishResult{}, errors.WithMessage(err, "Cannot save wiki index page")
+	}
+	return result, nil
+}
+
+// savePage creates the page or updates it with the content built from the
+// current one, leaving it untouched when the content is the same.
+func (p *WikiPublisher) savePage(title string, build func(current string) string) (app.PublishResult, error) {
+	slug := wikiSlug(title)
+	page, err := p.client.RetrieveWikiPage(slug)
+	if errors.IsNotFound(err) {
+		content := build("")
+		if err := p.client.CreateWikiPage(app.WikiPage{Title: title, Content: content, Format: wikiFormat}); err != nil {
+			return app.PublishResult{}, err
+		}
+		return app.PublishResult{Status: app.PublishCreated, Description: content}, nil
+	}
+	if err != nil {
+		return app.PublishResult{}, err
+	}
+
+	result := app.PublishResult{Status: app.PublishUnchanged, PreviousDescription: page.Content, Description: build(page.Content)}
+	if result.Description == page.Content {
+		return result, nil
+	}
+	if err := p.client.UpdateWikiPage(slug, app.WikiPage{Title: title, Content: result.Description, Format: wikiFormat}); err != nil {
+		return app.PublishResult{}, err
+	}
+	result.Status = app.PublishUpdated
+	return result, nil
+}
+
+// indexContent adds or replaces the entry in the entries of the current
+// index page, sorted by date then tag, newest first.
+func (p *WikiPublisher) indexContent(current string, entry wikiIndexEntry) string {
+	entries := []wikiIndexEntry{entry}
+	for _, line := range strings.Split(current, "\n") {
+		match := wikiIndexEntryRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if match != nil && match[1] != entry.tag {
+			entries = append(entries, wikiIndexEntry{tag: match[1], link: match[2], date: match[3]})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].date != entries[j].date {
+			return entries[i].date > entries[j].date
+		}
+		return entries[i].tag > entries[j].tag
+	})
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n\n", p.config.Prefix)
+	for _, e := range entries {
+		fmt.Fprintf(&out, "- [%s](%s) (%s)\n", e.tag, e.link, e.date)
+	}
+	return out.String()
+}
+
+// wikiSlug returns the slug GitLab derives from a page title.
+func wikiSlug(title string) string {
+	return strings.ReplaceAll(title, " ", "-")
+}
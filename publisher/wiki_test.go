@@ -0,0 +1,76 @@
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWiki keeps the wiki pages in memory, keyed by slug.
+type fakeWiki struct {
+	pages   map[string]app.WikiPage
+	updates int
+}
+
+func (w *fakeWiki) RetrieveWikiPage(slug string) (app.WikiPage, error) {
+	page, exists := w.pages[slug]
+	if !exists {
+		return app.WikiPage{}, errors.WithNotFound(errors.New("404 Not Found"), "gitlab_not_found")
+	}
+	return page, nil
+}
+
+func (w *fakeWiki) CreateWikiPage(page app.WikiPage) error {
+	page.Slug = wikiSlug(page.Title)
+	w.pages[page.Slug] = page
+	return nil
+}
+
+func (w *fakeWiki) UpdateWikiPage(slug string, page app.WikiPage) error {
+	w.updates++
+	page.Slug = slug
+	w.pages[slug] = page
+	return nil
+}
+
+func TestWikiPublisher_Publish(t *testing.T) {
+	wiki := &fakeWiki{pages: map[string]app.WikiPage{
+		"Releases": {Slug: "Releases", Content: "# Releases\n\n- [v1.0.0](Releases/v1.0.0) (2024-01-01)\n- [v1.1.0](Releases/v1.1.0) (2024-01-15)\n"},
+	}}
+	wikiPublisher := NewWikiPublisher(wiki, WikiConfig{})
+
+	note := testNote(1)
+	result, err := wikiPublisher.Publish(note)
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishCreated, result.Status)
+
+	page := wiki.pages["Releases/v1.2.0"]
+	assert.Equal(t, "Releases/v1.2.0", page.Title)
+	assert.Equal(t, "markdown", page.Format)
+	assert.Contains(t, page.Content, note.Markdown())
+	assert.Equal(t, "# Releases\n\n"+
+		"- [v1.2.0](Releases/v1.2.0) (2024-02-01)\n"+
+		"- [v1.1.0](Releases/v1.1.0) (2024-01-15)\n"+
+		"- [v1.0.0](Releases/v1.0.0) (2024-01-01)\n", wiki.pages["Releases"].Content)
+	assert.Equal(t, 1, wiki.updates)
+
+	// Publishing the same note again changes nothing.
+	result, err = wikiPublisher.Publish(note)
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishUnchanged, result.Status)
+	assert.Equal(t, 1, wiki.updates)
+
+	// A new note of the same tag replaces its index entry.
+	note.Date = time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)
+	result, err = wikiPublisher.Publish(note)
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishUpdated, result.Status)
+	assert.Equal(t, "# Releases\n\n"+
+		"- [v1.2.0](Releases/v1.2.0) (2024-02-02)\n"+
+		"- [v1.1.0](Releases/v1.1.0) (2024-01-15)\n"+
+		"- [v1.0.0](Releases/v1.0.0) (2024-01-01)\n", wiki.pages["Releases"].Content)
+}
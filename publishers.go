@@ -0,0 +1,297 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/publisher"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	publisherGitLab     = "gitlab"
+	publisherFile       = "file"
+	publisherStdout     = "stdout"
+	publisherSlack      = "slack"
+	publisherMattermost = "mattermost"
+	publisherTeams      = "teams"
+	publisherWebhook    = "webhook"
+	publisherEmail      = "email"
+	publisherWiki       = "wiki"
+)
+
+// publishersConfig lists the targets of the release note. Without
+// PUBLISHERS_FILE, it is the GitLab release followed by the targets
+// configured by env.
+type publishersConfig struct {
+	FailurePolicy string            `mapstructure:"failure_policy"`
+	Publishers    []publisherConfig `mapstructure:"publishers"`
+}
+
+// publisherConfig configures a target, the options left empty fall back to
+// the env config of the type, eg: SLACK_WEBHOOK_URL.
+type publisherConfig struct {
+	Type string `mapstructure:"type"`
+	// Enabled is true when not set.
+	Enabled *bool `mapstructure:"enabled"`
+	// Format is the format of the file and stdout targets.
+	Format string `mapstructure:"format"`
+	Path   string `mapstructure:"path"`
+
+	WebhookURL string `mapstructure:"webhook_url"`
+	Overflow   string `mapstructure:"overflow"`
+
+	URLs    []string `mapstructure:"urls"`
+	Secret  string   `mapstructure:"secret"`
+	Retries int      `mapstructure:"retries"`
+
+	SMTPHost      string   `mapstructure:"smtp_host"`
+	SMTPPort      int      `mapstructure:"smtp_port"`
+	SMTPUsername  string   `mapstructure:"smtp_username"`
+	SMTPPassword  string   `mapstructure:"smtp_password"`
+	SMTPTLS       string   `mapstructure:"smtp_tls"`
+	From          string   `mapstructure:"from"`
+	To            []string `mapstructure:"to"`
+	SubjectPrefix string   `mapstructure:"subject_prefix"`
+
+	Prefix string `mapstructure:"prefix"`
+}
+
+// loadPublishersConfig reads PUBLISHERS_FILE, eg:
+//
+//	failure_policy: fail_at_end
+//	publishers:
+//	  - type: gitlab
+//	  - type: file
+//	    path: release-notes/{tag}.md
+//	  - type: stdout
+//	    format: json
+//	  - type: slack
+//	    enabled: false
+//
+// The disabled targets are left out.
+func loadPublishersConfig(env envConfig) (publishersConfig, error) {
+	cfg := envPublishersConfig(env)
+	if env.PublishersFile != "" {
+		v := viper.New()
+		v.SetConfigFile(env.PublishersFile)
+		if err := v.ReadInConfig(); err != nil {
+			return publishersConfig{}, errors.WithStack(err)
+		}
+		cfg = publishersConfig{}
+		if err := v.Unmarshal(&cfg); err != nil {
+			return publishersConfig{}, errors.WithStack(err)
+		}
+	}
+
+	if cfg.FailurePolicy == "" {
+		cfg.FailurePolicy = env.PublishFailurePolicy
+	}
+	if cfg.FailurePolicy == "" {
+		cfg.FailurePolicy = app.FailAtEnd
+	}
+	if err := app.ValidateFailurePolicy(cfg.FailurePolicy); err != nil {
+		return publishersConfig{}, err
+	}
+
+	enabled := cfg.Publishers[:0]
+	for i, p := range cfg.Publishers {
+		if err := validatePublisherConfig(p); err != nil {
+			return publishersConfig{}, errors.WithMessagef(err, "publisher #%d", i+1)
+		}
+		if p.Enabled == nil || *p.Enabled {
+			enabled = append(enabled, p)
+		}
+	}
+	cfg.Publishers = enabled
+	return cfg, nil
+}
+
+func envPublishersConfig(env envConfig) publishersConfig {
+	cfg := publishersConfig{Publishers: []publisherConfig{{Type: publisherGitLab}}}
+	for _, p := range []struct {
+		publisherType string
+		configured    bool
+	}{
+		{publisherSlack, env.SlackWebhookURL != ""},
+		{publisherMattermost, env.MattermostWebhookURL != ""},
+		{publisherTeams, env.TeamsWebhookURL != ""},
+		{publisherWebhook, len(env.WebhookURLs) > 0},
+		{publisherEmail, env.SMTPHost != ""},
+		{publisherWiki, env.WikiPublish},
+	} {
+		if p.configured {
+			cfg.Publishers = append(cfg.Publishers, publisherConfig{Type: p.publisherType})
+		}
+	}
+	return cfg
+}
+
+func validatePublisherConfig(p publisherConfig) error {
+	switch p.Type {
+	case publisherFile, publisherStdout:
+		return publisher.ValidateFormat(p.Format)
+	case publisherGitLab, publisherSlack, publisherMattermost, publisherTeams, publisherWebhook, publisherEmail, publisherWiki:
+		if p.Format != "" {
+			return errors.Errorf("The %s publisher doesn't support formats.", p.Type)
+		}
+		return nil
+	default:
+		return errors.Errorf("Unsupported publisher type: %q", p.Type)
+	}
+}
+
+// linksRelease reports whether a publisher links the release, its URL
+// requires the project.
+func linksRelease(cfg publishersConfig) bool {
+	for _, p := range cfg.Publishers {
+		switch p.Type {
+		case publisherGitLab:
+		case publisherFile, publisherStdout:
+			if p.Format == publisher.FormatJSON {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// newPublishers returns the publishers of the config, the GitLab one
+// publishing the release of the tag.
+func newPublishers(cfg publishersConfig, env envConfig, client app.GitLabClient, gitLabSvc app.GitLabService, tag app.Tag) ([]app.Publisher, error) {
+	publishers := make([]app.Publisher, 0, len(cfg.Publishers))
+	for _, p := range cfg.Publishers {
+		pub, err := newPublisher(p, env, client, gitLabSvc, tag)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "%s publisher", p.Type)
+		}
+		publishers = append(publishers, pub)
+	}
+	return publishers, nil
+}
+
+func newPublisher(p publisherConfig, env envConfig, client app.GitLabClient, gitLabSvc app.GitLabService, tag app.Tag) (app.Publisher, error) {
+	switch p.Type {
+	case publisherGitLab:
+		return app.NewGitLabPublisher(gitLabSvc, tag), nil
+	case publisherFile:
+		return publisher.NewFilePublisher(p.Path, p.Format)
+	case publisherStdout:
+		return publisher.NewStdoutPublisher(p.Format)
+	case publisherSlack:
+		return publisher.NewSlackPublisher(chatConfig(p, env.SlackWebhookURL, env))
+	case publisherMattermost:
+		return publisher.NewMattermostPublisher(chatConfig(p, env.MattermostWebhookURL, env))
+	case publisherTeams:
+		return publisher.NewTeamsPublisher(chatConfig(p, env.TeamsWebhookURL, env))
+	case publisherWebhook:
+		return publisher.NewWebhookPublisher(publisher.WebhookConfig{
+			URLs:    orStrings(p.URLs, env.WebhookURLs),
+			Secret:  orString(p.Secret, env.WebhookSecret),
+			Retries: orInt(p.Retries, env.WebhookRetries),
+		})
+	case publisherEmail:
+		return publisher.NewEmailPublisher(publisher.EmailConfig{
+			Host:          orString(p.SMTPHost, env.SMTPHost),
+			Port:          orInt(p.SMTPPort, env.SMTPPort),
+			Username:      orString(p.SMTPUsername, env.SMTPUsername),
+			Password:      orString(p.SMTPPassword, env.SMTPPassword),
+			TLS:           orString(p.SMTPTLS, env.SMTPTLS),
+			From:          orString(p.From, env.EmailFrom),
+			To:            orStrings(p.To, emailRecipients(env)),
+			SubjectPrefix: orString(p.SubjectPrefix, env.EmailSubjectPrefix),
+		})
+	case publisherWiki:
+		return publisher.NewWikiPublisher(client, publisher.WikiConfig{Prefix: orString(p.Prefix, env.WikiPagePrefix)}), nil
+	default:
+		return nil, errors.Errorf("Unsupported publisher type: %q", p.Type)
+	}
+}
+
+func chatConfig(p publisherConfig, webhookURL string, env envConfig) publisher.ChatConfig {
+	return publisher.ChatConfig{
+		WebhookURL: orString(p.WebhookURL, webhookURL),
+		Overflow:   orString(p.Overflow, env.ChatOverflow),
+	}
+}
+
+// emailRecipients returns the recipients of the project in
+// EMAIL_PROJECT_RECIPIENTS, or EMAIL_TO.
+func emailRecipients(env envConfig) []string {
+	recipients, exists := env.EmailProjectRecipients[env.ProjectID]
+	if !exists {
+		return env.EmailTo
+	}
+
+	var to []string
+	for _, recipient := range strings.Split(recipients, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			to = append(to, recipient)
+		}
+	}
+	return to
+}
+
+// logPublisherResults logs the outcome of every target of the run.
+func logPublisherResults(tagName, runID string, results []app.PublisherResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			slog.Error("Publisher failed", "tag", tagName, "publisher", result.Name, "status", result.Result.Status, "run", runID, "error", result.Err)
+			continue
+		}
+		slog.Info("Published release", "tag", tagName, "publisher", result.Name, "status", result.Result.Status, "run", runID)
+	}
+}
+
+// gitLabResult returns the result of the GitLab release, if it was written,
+// even when the publisher failed afterwards.
+func gitLabResult(results []app.PublisherResult) (app.PublishResult, bool) {
+	for _, result := range results {
+		if result.Name != publisherGitLab {
+			continue
+		}
+		switch result.Result.Status {
+		case app.PublishCreated, app.PublishUpdated, app.PublishUnchanged:
+			return result.Result, true
+		}
+	}
+	return app.PublishResult{}, false
+}
+
+func orString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func orStrings(values, fallback []string) []string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
+
+func orInt(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// withoutAnnouncers filters out the publishers announcing the releases.
+func withoutAnnouncers(publishers []app.Publisher) []app.Publisher {
+	var kept []app.Publisher
+	for _, p := range publishers {
+		if announcer, ok := p.(app.Announcer); ok && announcer.Announces() {
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitLabResult(t *testing.T) {
+	written := app.PublishResult{Status: app.PublishUpdated, PreviousDescription: "old note", Description: "new note"}
+	tcs := []struct {
+		name    string
+		results []app.PublisherResult
+		ok      bool
+	}{
+		{"published", []app.PublisherResult{{Name: publisherFile, Result: app.PublishResult{Status: app.PublishCreated}}, {Name: publisherGitLab, Result: written}}, true},
+		{"failed after the write", []app.PublisherResult{{Name: publisherGitLab, Result: written, Err: errors.New("links failed")}}, true},
+		{"failed", []app.PublisherResult{{Name: publisherGitLab, Result: app.PublishResult{Status: app.PublishFailed}, Err: errors.New("401 Unauthorized")}}, false},
+		{"skipped", []app.PublisherResult{{Name: publisherGitLab, Result: app.PublishResult{Status: app.PublishSkipped}}}, false},
+		{"not a target", []app.PublisherResult{{Name: publisherFile, Result: written}}, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			result, ok := gitLabResult(tc.results)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				assert.Equal(t, written, result)
+			}
+		})
+	}
+}
+
+func TestNewPublishers_Env(t *testing.T) {
+	env := testEnv("https://gitlab.example.com/api/v4")
+	env.SlackWebhookURL = "https://hooks.slack.com/services/T/B/X"
+	env.TeamsWebhookURL = "https://example.webhook.office.com/webhookb2/X"
+	env.WebhookURLs = []string{"https://ci.example.com/hooks/release"}
+	env.WikiPublish = true
+
+	cfg := envPublishersConfig(env)
+	publishers, err := newPublishers(cfg, env, nil, nil, app.Tag{Name: "v1.0.0"})
+	assert.NoError(t, err)
+
+	var names []string
+	for _, p := range publishers {
+		names = append(names, p.Name())
+	}
+	assert.Equal(t, []string{publisherGitLab, "Slack", "Teams", publisherWebhook, publisherWiki}, names)
+
+	var kept []string
+	for _, p := range withoutAnnouncers(publishers) {
+		kept = append(kept, p.Name())
+	}
+	assert.Equal(t, []string{publisherGitLab, publisherWiki}, kept)
+}
+
+func TestLoadPublishersConfig_FailurePolicy(t *testing.T) {
+	env := testEnv("https://gitlab.example.com/api/v4")
+	cfg, err := loadPublishersConfig(env)
+	assert.NoError(t, err)
+	assert.Equal(t, app.FailAtEnd, cfg.FailurePolicy)
+
+	env.PublishFailurePolicy = app.FailFast
+	cfg, err = loadPublishersConfig(env)
+	assert.NoError(t, err)
+	assert.Equal(t, app.FailFast, cfg.FailurePolicy)
+
+	env.PublishersFile = writeFile(t, "publishers.yaml", "failure_policy: ignore\npublishers:\n  - type: gitlab\n")
+	cfg, err = loadPublishersConfig(env)
+	assert.NoError(t, err)
+	assert.Equal(t, app.FailIgnore, cfg.FailurePolicy)
+
+	env.PublishFailurePolicy = "retry"
+	env.PublishersFile = ""
+	_, err = loadPublishersConfig(env)
+	assert.EqualError(t, err, "Unsupported failure policy: retry")
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/store"
+)
+
+const (
+	// exitConfig is the exit code of invalid options or config files.
+	exitConfig = 3
+	// exitAuth is the exit code of the credentials rejected by GitLab.
+	exitAuth = 4
+	// exitNotFound is the exit code of a missing tag, project or run.
+	exitNotFound = 5
+	// exitNetwork is the exit code of the network errors and timeouts, and
+	// of GitLab being unavailable.
+	exitNetwork = 6
+	// exitPublish is the exit code of the publishers failing.
+	exitPublish = 7
+
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+
+	invalidConfigCode = "invalid_config"
+	internalErrorCode = "internal_error"
+	unknownCode       = "unknown"
+)
+
+// errorReport is the JSON error output.
+type errorReport struct {
+	Error errorDetails `json:"error"`
+}
+
+type errorDetails struct {
+	Message  string `json:"message"`
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+	// Details has the stack trace in verbose mode.
+	Details string `json:"details,omitempty"`
+}
+
+// configError marks the errors of the options and of the config files.
+func configError(err error) error {
+	return errors.WithInvalid(err, invalidConfigCode)
+}
+
+// errorCode returns the outermost code of the error chain.
+func errorCode(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if code := errors.ErrorCode(e); code != unknownCode {
+			return code
+		}
+	}
+	return unknownCode
+}
+
+// hasBehavior reports whether any error of the chain has the behavior.
+func hasBehavior(err error, is func(error) bool) bool {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if is(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCode classifies an error returned by a command.
+func exitCode(err error) int {
+	code := errorCode(err)
+	var netErr net.Error
+	switch {
+	case err == errDescriptionDiffers:
+		return exitFailure
+	case code == invalidArgumentsCode:
+		return exitUsage
+	case code == invalidConfigCode:
+		return exitConfig
+	case code == store.UnauthorizedCode:
+		return exitAuth
+	case hasBehavior(err, errors.IsNotFound):
+		return exitNotFound
+	case code == app.PublishFailedCode:
+		return exitPublish
+	case hasBehavior(err, errors.IsTemporary), hasBehavior(err, errors.IsTimeout), errors.As(err, &netErr):
+		return exitNetwork
+	default:
+		return exitFailure
+	}
+}
+
+// reportError writes the error in the format and returns its exit code. The
+// stack trace is only reported in verbose mode.
+func reportError(w io.Writer, err error, format string, verbose bool) int {
+	exit := exitCode(err)
+	if format == errorFormatJSON {
+		details := errorDetails{Message: err.Error(), Code: errorCode(err), ExitCode: exit}
+		if verbose {
+			details.Details = fmt.Sprintf("%+v", err)
+		}
+		_ = json.NewEncoder(w).Encode(errorReport{Error: details})
+		return exit
+	}
+
+	if verbose {
+		fmt.Fprintf(w, "Error: %+v\n", err)
+	} else {
+		fmt.Fprintf(w, "Error: %s\n", err)
+	}
+	return exit
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/store"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tcs := []struct {
+		name string
+		err  error
+		exit int
+		code string
+	}{
+		{"config", errors.WithMessage(configError(errors.New("Unsupported group by: size")), "project mygroup/api"), exitConfig, invalidConfigCode},
+		{"invalid arguments", errors.WithInvalid(errors.New("--from requires --tag."), invalidArgumentsCode), exitUsage, invalidArgumentsCode},
+		{"auth", errors.WithMessage(errors.WithCode(errors.New("401 Unauthorized"), store.UnauthorizedCode), "release v1.0.0"), exitAuth, store.UnauthorizedCode},
+		{"not found", errors.WithStack(errors.WithNotFound(errors.New("Cannot find tag \"v9\"."), app.TagNotFoundCode)), exitNotFound, app.TagNotFoundCode},
+		{"network", errors.WithStack(&net.DNSError{Err: "no such host", Name: "gitlab.example.com"}), exitNetwork, unknownCode},
+		{"unavailable", errors.WithMessage(errors.WithTemporary(errors.New("503 Service Unavailable"), "gitlab_unavailable"), "tags"), exitNetwork, "gitlab_unavailable"},
+		{"publish", errors.WithCode(errors.New("1 of 2 publishers failed: file: disk full"), app.PublishFailedCode), exitPublish, app.PublishFailedCode},
+		{"description differs", errDescriptionDiffers, exitFailure, "description_differs"},
+		{"other", errors.New("Cannot publish."), exitFailure, unknownCode},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.exit, exitCode(tc.err))
+			assert.Equal(t, tc.code, errorCode(tc.err))
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	err := errors.WithMessage(errors.WithNotFound(errors.New("Cannot find tag \"v9\"."), app.TagNotFoundCode), "project 42")
+
+	var out bytes.Buffer
+	assert.Equal(t, exitNotFound, reportError(&out, err, errorFormatText, false))
+	assert.Equal(t, "Error: project 42: Cannot find tag \"v9\".\n", out.String())
+
+	out.Reset()
+	assert.Equal(t, exitNotFound, reportError(&out, err, errorFormatJSON, false))
+	var report errorReport
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, errorDetails{Message: "project 42: Cannot find tag \"v9\".", Code: app.TagNotFoundCode, ExitCode: exitNotFound}, report.Error)
+
+	out.Reset()
+	reportError(&out, err, errorFormatJSON, true)
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Contains(t, report.Error.Details, "TestReportError")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	rollbackCommand    = "rollback"
+	defaultHistoryFile = ".rlsnote/history.jsonl"
+	runNotFoundCode    = "run_not_found"
+)
+
+// version is the tool version saved in the history, set at build time with
+// -ldflags "-X main.version=<version>".
+var version = "dev"
+
+// newRunID identifies the releases published by an invocation.
+func newRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// recordHistory saves the previous description of a published release.
+func recordHistory(history app.HistoryStore, runID, projectID, tag string, result app.PublishResult) error {
+	if result.Status == app.PublishUnchanged {
+		return nil
+	}
+
+	return history.Append(app.HistoryEntry{
+		RunID:               runID,
+		ProjectID:           projectID,
+		Tag:                 tag,
+		Timestamp:           time.Now().UTC(),
+		Status:              result.Status,
+		PreviousDescription: result.PreviousDescription,
+		Description:         result.Description,
+		PreviousMilestones:  result.PreviousMilestones,
+		Milestones:          result.Milestones,
+		ToolVersion:         version,
+	})
+}
+
+// runRollback restores the releases published by a run, or lists the runs
+// when no run id is given. The rollback is itself recorded as a new run.
+func runRollback(env envConfig, history app.HistoryStore, runID, tag string) error {
+	entries, err := history.List()
+	if err != nil {
+		return err
+	}
+
+	if runID == "" {
+		printRuns(entries)
+		return nil
+	}
+
+	selected := app.EntriesOfRun(entries, runID, tag)
+	if len(selected) == 0 {
+		return errors.WithNotFound(errors.Errorf("No release published by run %q.", runID), runNotFoundCode)
+	}
+
+	rollbackRunID := newRunID()
+	failed := 0
+	// Newest first, a release published twice by the run ends with its oldest description.
+	for i := len(selected) - 1; i >= 0; i-- {
+		entry := selected[i]
+		projectEnv := env
+		projectEnv.ProjectID = entry.ProjectID
+		gitLabSvc := newGitLabService(projectEnv, newGitLabClient(projectEnv), nil)
+
+		result, err := gitLabSvc.Rollback(entry)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Cannot roll back release %s of project %s: %s\n", entry.Tag, entry.ProjectID, err)
+			continue
+		}
+
+		fmt.Printf("Release %s of project %s %s\n", entry.Tag, entry.ProjectID, result.Status)
+		if err := recordHistory(history, rollbackRunID, entry.ProjectID, entry.Tag, result); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Rollback run %s\n", rollbackRunID)
+	if failed > 0 {
+		return errors.Errorf("%d of %d releases failed to roll back.", failed, len(selected))
+	}
+	return nil
+}
+
+func printRuns(entries []app.HistoryEntry) {
+	type run struct {
+		id        string
+		timestamp time.Time
+		releases  int
+		version   string
+	}
+
+	var runs []*run
+	runByID := make(map[string]*run)
+	for _, entry := range entries {
+		r, exists := runByID[entry.RunID]
+		if !exists {
+			r = &run{id: entry.RunID, timestamp: entry.Timestamp, version: entry.ToolVersion}
+			runByID[entry.RunID] = r
+			runs = append(runs, r)
+		}
+		r.releases++
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tTIMESTAMP\tRELEASES\tVERSION")
+	for _, r := range runs {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.id, r.timestamp.Format(time.RFC3339), r.releases, r.version)
+	}
+	w.Flush()
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gitLab-rls-note/pkg/errors"
+)
+
+const (
+	serveCommand = "serve"
+
+	defaultServeAddr      = ":8080"
+	defaultServeWorkers   = 2
+	defaultServeQueueSize = 100
+	defaultDedupSeconds   = 600
+
+	gitLabTokenHeader = "X-Gitlab-Token"
+	tagPushKind       = "tag_push"
+	tagRefPrefix      = "refs/tags/"
+	// deletedTagSHA is the "after" commit of the push deleting a tag.
+	deletedTagSHA   = "0000000000000000000000000000000000000000"
+	maxHookBodySize = 1 << 20
+	shutdownTimeout = 30 * time.Second
+)
+
+// tagPushEvent is the payload of the tag push events of project hooks, and
+// of system hooks with event_name instead of object_kind.
+type tagPushEvent struct {
+	ObjectKind string `json:"object_kind"`
+	EventName  string `json:"event_name"`
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	ProjectID  int    `json:"project_id"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type releaseJob struct {
+	projectID string
+	tag       string
+}
+
+// releaseServer generates the release notes of the tags pushed to GitLab,
+// the events are queued and processed by a fixed number of workers. It also
+// serves the notes API.
+type releaseServer struct {
+	env      envConfig
+	api      *notesAPI
+	opts     runOptions
+	token    string
+	tagRegex *regexp.Regexp
+	queue    chan releaseJob
+	workers  int
+	// run generates and publishes the release of a job.
+	run func(env envConfig, opts runOptions) error
+
+	mu sync.Mutex
+	// jobs holds the queued and running jobs with a zero time, and the
+	// succeeded jobs with their end time for the de-duplication window.
+	jobs        map[releaseJob]time.Time
+	dedupWindow time.Duration
+}
+
+type statusResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func newReleaseServer(env envConfig, opts runOptions) (*releaseServer, error) {
+	tagRegex, err := regexp.Compile(env.TargetTagRegex)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	workers, queueSize, dedupSeconds := env.ServeWorkers, env.ServeQueueSize, env.ServeDedupSeconds
+	if workers < 1 {
+		workers = defaultServeWorkers
+	}
+	if queueSize < 1 {
+		queueSize = defaultServeQueueSize
+	}
+	if dedupSeconds == 0 {
+		dedupSeconds = defaultDedupSeconds
+	}
+	return &releaseServer{
+		env:         env,
+		api:         newNotesAPI(env),
+		opts:        opts,
+		token:       env.WebhookToken,
+		tagRegex:    tagRegex,
+		queue:       make(chan releaseJob, queueSize),
+		workers:     workers,
+		run:         runRecovered,
+		jobs:        make(map[releaseJob]time.Time),
+		dedupWindow: time.Duration(dedupSeconds) * time.Second,
+	}, nil
+}
+
+// runServe serves the hook endpoint and the notes API until SIGINT or SIGTERM, then finishes
+// the queued jobs.
+func runServe(env envConfig, opts runOptions) error {
+	s, err := newReleaseServer(env, opts)
+	if err != nil {
+		return configError(err)
+	}
+
+	addr := env.ServeAddr
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	httpServer := &http.Server{Addr: addr, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range s.queue {
+				s.process(job)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.token == "" {
+			slog.Warn("Missing GITLAB_WEBHOOK_TOKEN, the hook endpoint is disabled")
+		}
+		if s.api.token == "" {
+			slog.Warn("Missing API_TOKEN, the notes API is disabled")
+		}
+		slog.Info("Serving", "addr", addr, "workers", s.workers)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = httpServer.Shutdown(shutdownCtx)
+	}
+	close(s.queue)
+	wg.Wait()
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return errors.WithStack(err)
+}
+
+func (s *releaseServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	// The hook endpoint and the notes API must be protected by a token.
+	if s.token != "" {
+		mux.HandleFunc("/hooks", s.handleHook)
+	}
+	if s.api.token != "" {
+		mux.Handle(projectsPath, s.api)
+	}
+	mux.HandleFunc("/healthz", s.handleHealth)
+	return mux
+}
+
+func (s *releaseServer) handleHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, statusResponse{Status: "error", Message: "Method not allowed."})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(gitLabTokenHeader)), []byte(s.token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, statusResponse{Status: "error", Message: "Invalid token."})
+		return
+	}
+
+	var event tagPushEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxHookBodySize)).Decode(&event); err != nil {
+		writeJSON(w, http.StatusBadRequest, statusResponse{Status: "error", Message: "Invalid event payload."})
+		return
+	}
+
+	job, reason := s.jobOf(event)
+	if reason != "" {
+		writeJSON(w, http.StatusOK, statusResponse{Status: "ignored", Message: reason})
+		return
+	}
+
+	status, code := s.enqueue(job)
+	if code == http.StatusAccepted {
+		slog.Info("Release queued", "project", job.projectID, "tag", job.tag)
+	}
+	writeJSON(w, code, statusResponse{Status: status})
+}
+
+// jobOf returns the job of a tag push event, or why the event is ignored.
+func (s *releaseServer) jobOf(event tagPushEvent) (releaseJob, string) {
+	if event.ObjectKind != tagPushKind && event.EventName != tagPushKind {
+		return releaseJob{}, "Not a tag push event."
+	}
+	if event.After == deletedTagSHA {
+		return releaseJob{}, "Deleted tag."
+	}
+
+	tag := strings.TrimPrefix(event.Ref, tagRefPrefix)
+	if tag == event.Ref || !s.tagRegex.MatchString(tag) {
+		return releaseJob{}, "Tag not matching TARGET_TAG_REGEX."
+	}
+
+	projectID := event.Project.PathWithNamespace
+	if event.ProjectID != 0 {
+		projectID = strconv.Itoa(event.ProjectID)
+	}
+	if projectID == "" {
+		return releaseJob{}, "Missing project."
+	}
+	return releaseJob{projectID: projectID, tag: tag}, ""
+}
+
+// enqueue queues the job unless it is queued, running or succeeded within
+// the de-duplication window.
+func (s *releaseServer) enqueue(job releaseJob) (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for j, end := range s.jobs {
+		if !end.IsZero() && now.Sub(end) >= s.dedupWindow {
+			delete(s.jobs, j)
+		}
+	}
+	if _, exists := s.jobs[job]; exists {
+		return "duplicate", http.StatusOK
+	}
+
+	select {
+	case s.queue <- job:
+		s.jobs[job] = time.Time{}
+		return "queued", http.StatusAccepted
+	default:
+		return "queue full", http.StatusServiceUnavailable
+	}
+}
+
+func (s *releaseServer) process(job releaseJob) {
+	env, opts := s.env, s.opts
+	env.ProjectID = job.projectID
+	opts.tag = job.tag
+	opts.runID = newRunID()
+
+	start := time.Now()
+	// The hooks fire for the tags pushed to any branch.
+	inTargetBranch, err := newGitLabService(env, newGitLabClient(env), nil).IsTagInTargetBranch(job.tag)
+	if err == nil && inTargetBranch {
+		err = s.run(env, opts)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil && !inTargetBranch {
+		s.jobs[job] = time.Now()
+		slog.Info("Skipped tag not on the target branch", "project", job.projectID, "tag", job.tag)
+		return
+	}
+	if err != nil {
+		// A failed job can be retried by pushing the event again.
+		delete(s.jobs, job)
+		slog.Error("Release failed", "project", job.projectID, "tag", job.tag, "duration", time.Since(start).Round(time.Millisecond), "error", err)
+		return
+	}
+	s.jobs[job] = time.Now()
+	slog.Info("Release done", "project", job.projectID, "tag", job.tag, "duration", time.Since(start).Round(time.Millisecond))
+}
+
+func (s *releaseServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"queued":  len(s.queue),
+		"workers": s.workers,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReleaseServer(t *testing.T, env envConfig) *releaseServer {
+	env.WebhookToken = "secret"
+	s, err := newReleaseServer(env, runOptions{})
+	assert.NoError(t, err)
+	return s
+}
+
+func postHook(s *releaseServer, token, body string) (int, statusResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	req.Header.Set(gitLabTokenHeader, token)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, req)
+
+	var resp statusResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func tagPush(ref, after string) string {
+	return `{"object_kind":"tag_push","ref":"` + ref + `","after":"` + after + `","project_id":42}`
+}
+
+func TestHandleHook_Token(t *testing.T) {
+	s := newTestReleaseServer(t, testEnv(""))
+
+	code, resp := postHook(s, "wrong", tagPush("refs/tags/v1.0.0", "abc"))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Equal(t, "Invalid token.", resp.Message)
+
+	code, _ = postHook(s, "", tagPush("refs/tags/v1.0.0", "abc"))
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Empty(t, s.queue)
+
+	// Without token, the hook endpoint is not mounted.
+	s.token = ""
+	code, _ = postHook(s, "", tagPush("refs/tags/v1.0.0", "abc"))
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestHandleHook_Ignored(t *testing.T) {
+	s := newTestReleaseServer(t, testEnv(""))
+
+	tcs := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{"push", `{"object_kind":"push","ref":"refs/heads/main","project_id":42}`, "Not a tag push event."},
+		{"deleted tag", tagPush("refs/tags/v1.0.0", deletedTagSHA), "Deleted tag."},
+		{"tag not matching", tagPush("refs/tags/nightly", "abc"), "Tag not matching TARGET_TAG_REGEX."},
+		{"branch ref", tagPush("refs/heads/v1", "abc"), "Tag not matching TARGET_TAG_REGEX."},
+		{"missing project", `{"event_name":"tag_push","ref":"refs/tags/v1.0.0","after":"abc"}`, "Missing project."},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := postHook(s, "secret", tc.body)
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, statusResponse{Status: "ignored", Message: tc.message}, resp)
+		})
+	}
+	assert.Empty(t, s.queue)
+
+	code, _ := postHook(s, "secret", "not json")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHandleHook_Enqueue(t *testing.T) {
+	env := testEnv("")
+	env.ServeQueueSize = 2
+	s := newTestReleaseServer(t, env)
+
+	code, resp := postHook(s, "secret", tagPush("refs/tags/v1.0.0", "abc"))
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "queued", resp.Status)
+	assert.Equal(t, releaseJob{projectID: "42", tag: "v1.0.0"}, <-s.queue)
+
+	// The job is running until processed.
+	code, resp = postHook(s, "secret", tagPush("refs/tags/v1.0.0", "abc"))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "duplicate", resp.Status)
+
+	code, _ = postHook(s, "secret", tagPush("refs/tags/v1.1.0", "abc"))
+	assert.Equal(t, http.StatusAccepted, code)
+	code, _ = postHook(s, "secret", `{"event_name":"tag_push","ref":"refs/tags/v1.1.0","after":"abc","project":{"path_with_namespace":"mygroup/web"}}`)
+	assert.Equal(t, http.StatusAccepted, code)
+
+	code, resp = postHook(s, "secret", tagPush("refs/tags/v1.2.0", "abc"))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "queue full", resp.Status)
+}
+
+func TestProcess_TargetBranch(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/repository/tags/v1.0.0":     `{"name":"v1.0.0","commit":{"id":"a1"}}`,
+		"GET /projects/42/repository/commits/a1/refs": `[{"name":"main"}]`,
+		"GET /projects/42/repository/tags/v1.0.1":     `{"name":"v1.0.1","commit":{"id":"b1"}}`,
+		"GET /projects/42/repository/commits/b1/refs": `[{"name":"hotfix"}]`,
+	})
+	s := newTestReleaseServer(t, testEnv(endpoint))
+	var tags []string
+	s.run = func(env envConfig, opts runOptions) error {
+		assert.Equal(t, "42", env.ProjectID)
+		tags = append(tags, opts.tag)
+		return nil
+	}
+
+	s.process(releaseJob{projectID: "42", tag: "v1.0.0"})
+	s.process(releaseJob{projectID: "42", tag: "v1.0.1"})
+	s.process(releaseJob{projectID: "42", tag: "v9.9.9"})
+	assert.Equal(t, []string{"v1.0.0"}, tags)
+
+	// The skipped tag is de-duplicated, the failed one can be pushed again.
+	assert.Contains(t, s.jobs, releaseJob{projectID: "42", tag: "v1.0.1"})
+	assert.NotContains(t, s.jobs, releaseJob{projectID: "42", tag: "v9.9.9"})
+}
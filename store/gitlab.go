@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"gitLab-rls-note/app"
@@ -15,15 +18,29 @@ import (
 	"strconv"
 )
 
+// UnauthorizedCode is the code of the credentials rejected by GitLab.
+const UnauthorizedCode = "gitlab_unauthorized"
+
 const (
 	GitlabTimeFormat = time.RFC3339Nano
+
+	projectNotFoundCode    = "project_not_found"
+	notFoundCode           = "gitlab_not_found"
+	unavailableCode        = "gitlab_unavailable"
+	requestFailedCode      = "gitlab_request_failed"
+	maxErrorResponseLength = 200
 )
 
 type gitlabClient struct {
 	personalToken string
 	apiEndpoint   string
 	projectID     string
+	groupID       string
 	cookie        string
+	logger        *slog.Logger
+
+	mu   sync.Mutex
+	repo *app.Repo
 }
 
 func NewGitlabClient(personalToken, apiEndpoint, projectID, cookie string) app.GitLabClient {
@@ -32,11 +49,26 @@ func NewGitlabClient(personalToken, apiEndpoint, projectID, cookie string) app.G
 		apiEndpoint:   apiEndpoint,
 		projectID:     projectID,
 		cookie:        cookie,
+		logger:        slog.Default().With("project", projectID),
+	}
+}
+
+func NewGitlabGroupClient(personalToken, apiEndpoint, groupID, cookie string) app.GitLabGroupClient {
+	return &gitlabClient{
+		personalToken: personalToken,
+		apiEndpoint:   apiEndpoint,
+		groupID:       groupID,
+		cookie:        cookie,
+		logger:        slog.Default().With("group", groupID),
 	}
 }
 
 func (g *gitlabClient) RetrieveIssues(prs app.ListIssueParams, pg *app.Pagination) ([]app.Issue, error) {
-	path := fmt.Sprintf("/projects/%s/issues", g.projectID)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := projectPath + "/issues"
 	query := url.Values{
 		"updated_before": {prs.UpdatedBefore.Format(GitlabTimeFormat)},
 		"updated_after":  {prs.UpdatedAfter.Format(GitlabTimeFormat)},
@@ -59,8 +91,18 @@ func (g *gitlabClient) RetrieveIssues(prs app.ListIssueParams, pg *app.Paginatio
 }
 
 func (g *gitlabClient) RetrieveRepo() (app.Repo, error) {
-	path := fmt.Sprintf("/projects/%s", g.projectID)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.repo != nil {
+		return *g.repo, nil
+	}
+
+	path := fmt.Sprintf("/projects/%s", escapePathSegment(g.projectID))
 	_, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path})
+	if errors.IsNotFound(err) {
+		return app.Repo{}, errors.WithNotFound(errors.Errorf("Project %q not found, check the project id or path and the token permissions.", g.projectID), projectNotFoundCode)
+	}
 	if err != nil {
 		return app.Repo{}, err
 	}
@@ -70,11 +112,37 @@ func (g *gitlabClient) RetrieveRepo() (app.Repo, error) {
 		return app.Repo{}, errors.WithStack(err)
 	}
 
+	g.repo = &repo
 	return repo, nil
 }
 
+// IsProjectID reports whether the project is identified by its numeric id,
+// instead of its namespaced path.
+func IsProjectID(projectID string) bool {
+	_, err := strconv.Atoi(projectID)
+	return err == nil
+}
+
+// projectPath returns the API path of the project by its numeric id. A
+// namespaced project path is resolved once and cached.
+func (g *gitlabClient) projectPath() (string, error) {
+	if IsProjectID(g.projectID) {
+		return fmt.Sprintf("/projects/%s", g.projectID), nil
+	}
+
+	repo, err := g.RetrieveRepo()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/projects/%d", repo.ID), nil
+}
+
 func (g *gitlabClient) RetrieveMergeRequests(prs app.ListMReqParams, pg *app.Pagination) ([]app.MergeRequest, error) {
-	path := fmt.Sprintf("/projects/%s/merge_requests", g.projectID)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := projectPath + "/merge_requests"
 
 	query := url.Values{
 		"target_branch":  {prs.TargetBranch},
@@ -100,7 +168,11 @@ func (g *gitlabClient) RetrieveMergeRequests(prs app.ListMReqParams, pg *app.Pag
 }
 
 func (g *gitlabClient) RetrieveMergeRequestCommits(merge_request_iid int, pg *app.Pagination) ([]app.MRCommit, error) {
-	path := fmt.Sprintf("/projects/%s/merge_requests/%d/commits", g.projectID, merge_request_iid)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/merge_requests/%d/commits", projectPath, merge_request_iid)
 	query := url.Values{
 		"page":     {strconv.Itoa(pg.Page)},
 		"per_page": {strconv.Itoa(pg.PerPage)},
@@ -119,8 +191,36 @@ func (g *gitlabClient) RetrieveMergeRequestCommits(merge_request_iid int, pg *ap
 	return commits, nil
 }
 
+func (g *gitlabClient) RetrieveMergeRequestChanges(merge_request_iid int, pg *app.Pagination) ([]app.MRChange, error) {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/merge_requests/%d/diffs", projectPath, merge_request_iid)
+	query := url.Values{
+		"page":     {strconv.Itoa(pg.Page)},
+		"per_page": {strconv.Itoa(pg.PerPage)},
+	}
+	header, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []app.MRChange
+	if err := json.Unmarshal(body, &changes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pg.Page = g.getNextPage(header)
+	return changes, nil
+}
+
 func (g *gitlabClient) RetrieveTags(pg *app.Pagination) ([]app.Tag, error) {
-	path := fmt.Sprintf("/projects/%s/repository/tags", g.projectID)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := projectPath + "/repository/tags"
 	query := url.Values{
 		"page":     {strconv.Itoa(pg.Page)},
 		"per_page": {strconv.Itoa(pg.PerPage)},
@@ -139,8 +239,30 @@ func (g *gitlabClient) RetrieveTags(pg *app.Pagination) ([]app.Tag, error) {
 	return tags, nil
 }
 
+func (g *gitlabClient) RetrieveTag(tagName string) (app.Tag, error) {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return app.Tag{}, err
+	}
+	path := fmt.Sprintf("%s/repository/tags/%s", projectPath, escapePathSegment(tagName))
+	_, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path})
+	if err != nil {
+		return app.Tag{}, err
+	}
+
+	var tag app.Tag
+	if err := json.Unmarshal(body, &tag); err != nil {
+		return app.Tag{}, errors.WithStack(err)
+	}
+	return tag, nil
+}
+
 func (g *gitlabClient) RetrieveCommitRefsBySHA(sha string, query url.Values) ([]app.CommitRef, error) {
-	path := fmt.Sprintf("/projects/%s/repository/commits/%s/refs", g.projectID, sha)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/repository/commits/%s/refs", projectPath, escapePathSegment(sha))
 	_, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
 	if err != nil {
 		return nil, err
@@ -154,8 +276,40 @@ func (g *gitlabClient) RetrieveCommitRefsBySHA(sha string, query url.Values) ([]
 	return commitRefs, nil
 }
 
+func (g *gitlabClient) RetrieveRelease(tagName string) (app.Release, error) {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return app.Release{}, err
+	}
+	path := fmt.Sprintf("%s/releases/%s", projectPath, escapePathSegment(tagName))
+	_, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path})
+	if err != nil {
+		return app.Release{}, err
+	}
+
+	// The release API returns milestones as objects.
+	var release struct {
+		Name        string          `json:"tag_name"`
+		Description string          `json:"description"`
+		Milestones  []app.Milestone `json:"milestones"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return app.Release{}, errors.WithStack(err)
+	}
+
+	milestones := make([]string, 0, len(release.Milestones))
+	for _, milestone := range release.Milestones {
+		milestones = append(milestones, milestone.Title)
+	}
+	return app.Release{Name: release.Name, Description: release.Description, Milestones: milestones}, nil
+}
+
 func (g *gitlabClient) CreateTagRelease(body app.Release) error {
-	path := fmt.Sprintf("/projects/%s/releases", g.projectID)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := projectPath + "/releases"
 	bodyJSON, err := json.Marshal(body)
 	if err != nil {
 		return errors.WithStack(err)
@@ -170,8 +324,20 @@ func (g *gitlabClient) CreateTagRelease(body app.Release) error {
 }
 
 func (g *gitlabClient) UpdateTagRelease(body app.Release) error {
-	path := fmt.Sprintf("/projects/%s/releases/%s", g.projectID, body.Name)
-	bodyJSON, err := json.Marshal(body)
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/releases/%s", projectPath, escapePathSegment(body.Name))
+	// Nil milestones are left untouched, an empty list removes them.
+	update := struct {
+		app.Release
+		Milestones *[]string `json:"milestones,omitempty"`
+	}{Release: body}
+	if body.Milestones != nil {
+		update.Milestones = &body.Milestones
+	}
+	bodyJSON, err := json.Marshal(update)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -184,6 +350,197 @@ func (g *gitlabClient) UpdateTagRelease(body app.Release) error {
 	return nil
 }
 
+func (g *gitlabClient) DeleteTagRelease(tagName string) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/releases/%s", projectPath, escapePathSegment(tagName))
+	_, _, err = g.makeRequest(requestIn{method: http.MethodDelete, path: path})
+	return err
+}
+
+func (g *gitlabClient) RetrieveReleaseLinks(tagName string, pg *app.Pagination) ([]app.ReleaseLink, error) {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/releases/%s/assets/links", projectPath, escapePathSegment(tagName))
+	query := url.Values{
+		"page":     {strconv.Itoa(pg.Page)},
+		"per_page": {strconv.Itoa(pg.PerPage)},
+	}
+	header, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	var links []app.ReleaseLink
+	if err := json.Unmarshal(body, &links); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pg.Page = g.getNextPage(header)
+	return links, nil
+}
+
+func (g *gitlabClient) CreateReleaseLink(tagName string, link app.ReleaseLink) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/releases/%s/assets/links", projectPath, escapePathSegment(tagName))
+	bodyJSON, err := json.Marshal(link)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, _, err = g.makeRequest(requestIn{method: http.MethodPost, path: path, body: bodyJSON})
+	return err
+}
+
+func (g *gitlabClient) UpdateReleaseLink(tagName string, link app.ReleaseLink) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/releases/%s/assets/links/%d", projectPath, escapePathSegment(tagName), link.ID)
+	bodyJSON, err := json.Marshal(link)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, _, err = g.makeRequest(requestIn{method: http.MethodPut, path: path, body: bodyJSON})
+	return err
+}
+
+func (g *gitlabClient) DeleteReleaseLink(tagName string, linkID int) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/releases/%s/assets/links/%d", projectPath, escapePathSegment(tagName), linkID)
+	_, _, err = g.makeRequest(requestIn{method: http.MethodDelete, path: path})
+	return err
+}
+
+func (g *gitlabClient) RetrieveWikiPage(slug string) (app.WikiPage, error) {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return app.WikiPage{}, err
+	}
+	path := fmt.Sprintf("%s/wikis/%s", projectPath, escapePathSegment(slug))
+	_, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path})
+	if err != nil {
+		return app.WikiPage{}, err
+	}
+
+	var page app.WikiPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return app.WikiPage{}, errors.WithStack(err)
+	}
+	return page, nil
+}
+
+func (g *gitlabClient) CreateWikiPage(page app.WikiPage) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	bodyJSON, err := json.Marshal(page)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, _, err = g.makeRequest(requestIn{method: http.MethodPost, path: projectPath + "/wikis", body: bodyJSON})
+	return err
+}
+
+func (g *gitlabClient) UpdateWikiPage(slug string, page app.WikiPage) error {
+	projectPath, err := g.projectPath()
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("%s/wikis/%s", projectPath, escapePathSegment(slug))
+	bodyJSON, err := json.Marshal(page)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, _, err = g.makeRequest(requestIn{method: http.MethodPut, path: path, body: bodyJSON})
+	return err
+}
+
+func (g *gitlabClient) RetrieveGroupProjects(pg *app.Pagination) ([]app.Repo, error) {
+	path := fmt.Sprintf("/groups/%s/projects", escapePathSegment(g.groupID))
+	query := url.Values{
+		"include_subgroups": {"true"},
+		"archived":          {"false"},
+		"page":              {strconv.Itoa(pg.Page)},
+		"per_page":          {strconv.Itoa(pg.PerPage)},
+	}
+	header, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []app.Repo
+	if err := json.Unmarshal(body, &projects); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pg.Page = g.getNextPage(header)
+	return projects, nil
+}
+
+func (g *gitlabClient) RetrieveGroupMergeRequests(prs app.ListMReqParams, pg *app.Pagination) ([]app.MergeRequest, error) {
+	path := fmt.Sprintf("/groups/%s/merge_requests", escapePathSegment(g.groupID))
+	query := url.Values{
+		"target_branch":  {prs.TargetBranch},
+		"scope":          {"all"},
+		"updated_before": {prs.UpdatedBefore.Format(GitlabTimeFormat)},
+		"updated_after":  {prs.UpdatedAfter.Format(GitlabTimeFormat)},
+		"state":          {prs.State},
+		"page":           {strconv.Itoa(pg.Page)},
+		"per_page":       {strconv.Itoa(pg.PerPage)},
+	}
+	header, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	var mergeRequests []app.MergeRequest
+	if err := json.Unmarshal(body, &mergeRequests); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pg.Page = g.getNextPage(header)
+	return mergeRequests, nil
+}
+
+func (g *gitlabClient) RetrieveGroupIssues(prs app.ListIssueParams, pg *app.Pagination) ([]app.Issue, error) {
+	path := fmt.Sprintf("/groups/%s/issues", escapePathSegment(g.groupID))
+	query := url.Values{
+		"updated_before": {prs.UpdatedBefore.Format(GitlabTimeFormat)},
+		"updated_after":  {prs.UpdatedAfter.Format(GitlabTimeFormat)},
+		"state":          {prs.State},
+		"page":           {strconv.Itoa(pg.Page)},
+		"per_page":       {strconv.Itoa(pg.PerPage)},
+	}
+	header, body, err := g.makeRequest(requestIn{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []app.Issue
+	if err := json.Unmarshal(body, &issues); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pg.Page = g.getNextPage(header)
+	return issues, nil
+}
+
 func (g *gitlabClient) makeRequest(reqIn requestIn) (http.Header, []byte, error) {
 	client := &http.Client{}
 	fullURL := fmt.Sprintf("%s%s?%s", g.apiEndpoint, reqIn.path, reqIn.query.Encode())
@@ -203,8 +560,11 @@ func (g *gitlabClient) makeRequest(reqIn requestIn) (http.Header, []byte, error)
 		req.Header.Add("Content-Type", "application/json")
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
 	if err != nil {
+		g.logger.Warn("GitLab request failed", "method", reqIn.method, "path", reqIn.path,
+			"duration", time.Since(start), "error", err)
 		return nil, nil, errors.WithStack(err)
 	}
 	defer resp.Body.Close()
@@ -212,10 +572,37 @@ func (g *gitlabClient) makeRequest(reqIn requestIn) (http.Header, []byte, error)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
+	g.logger.Debug("GitLab request", "method", reqIn.method, "path", reqIn.path, "query", reqIn.query.Encode(),
+		"status", resp.StatusCode, "duration", time.Since(start), "next_page", resp.Header.Get("X-Next-Page"))
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, responseError(reqIn, resp.StatusCode, responseBody)
+	}
 
 	return resp.Header, responseBody, nil
 }
 
+// responseError annotates the error of a failed response with a code and
+// behavior depending on its status.
+func responseError(reqIn requestIn, statusCode int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	if len(message) > maxErrorResponseLength {
+		message = message[:maxErrorResponseLength] + "..."
+	}
+	err := errors.Errorf("GitLab %s %s responded %d: %s", reqIn.method, reqIn.path, statusCode, message)
+
+	switch {
+	case statusCode == http.StatusNotFound:
+		return errors.WithNotFound(err, notFoundCode)
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return errors.WithCode(err, UnauthorizedCode)
+	case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return errors.WithTemporary(err, unavailableCode)
+	default:
+		return errors.WithCode(err, requestFailedCode)
+	}
+}
+
 type requestIn struct {
 	method string
 	path   string
@@ -223,6 +610,12 @@ type requestIn struct {
 	body   []byte
 }
 
+// escapePathSegment escapes a project path, tag or ref to be used as a single
+// path segment. "+" is escaped too since some servers decode it as a space.
+func escapePathSegment(segment string) string {
+	return strings.ReplaceAll(url.PathEscape(segment), "+", "%2B")
+}
+
 func (g *gitlabClient) getNextPage(header http.Header) int {
 	nextPageStr := header.Get("X-Next-Page")
 	nextPage, err := strconv.Atoi(nextPageStr)
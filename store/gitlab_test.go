@@ -0,0 +1,253 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitLab serves canned responses keyed by method and escaped path, and
+// records the requests it receives.
+type fakeGitLab struct {
+	mu        sync.Mutex
+	responses map[string]string
+	requests  []fakeRequest
+}
+
+type fakeRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+func newFakeGitLab(t *testing.T, responses map[string]string) (*fakeGitLab, string) {
+	f := &fakeGitLab{responses: responses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		path := strings.TrimPrefix(r.URL.EscapedPath(), "/api/v4")
+
+		f.mu.Lock()
+		f.requests = append(f.requests, fakeRequest{method: r.Method, path: path, body: string(body)})
+		resp, exists := f.responses[r.Method+" "+path]
+		f.mu.Unlock()
+
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Not Found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(server.Close)
+	return f, server.URL + "/api/v4"
+}
+
+func (f *fakeGitLab) count(method, path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, req := range f.requests {
+		if req.method == method && req.path == path {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeGitLab) lastBody(method, path string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if f.requests[i].method == method && f.requests[i].path == path {
+			return f.requests[i].body
+		}
+	}
+	return ""
+}
+
+func TestUpdateTagRelease_EscapesTagName(t *testing.T) {
+	tcs := []struct {
+		tag  string
+		path string
+	}{
+		{"release/2024.1", "/projects/42/releases/release%2F2024.1"},
+		{"v1.0+build.5", "/projects/42/releases/v1.0%2Bbuild.5"},
+		{"v1.0-ünïcode", "/projects/42/releases/v1.0-%C3%BCn%C3%AFcode"},
+		{"v1 beta", "/projects/42/releases/v1%20beta"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tag, func(t *testing.T) {
+			fake, endpoint := newFakeGitLab(t, map[string]string{"PUT " + tc.path: `{}`})
+			client := NewGitlabClient("token", endpoint, "42", "")
+
+			err := client.UpdateTagRelease(app.Release{Name: tc.tag, Description: "note"})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, fake.count(http.MethodPut, tc.path))
+		})
+	}
+}
+
+func TestUpdateTagRelease_Milestones(t *testing.T) {
+	tcs := []struct {
+		name       string
+		milestones []string
+		body       string
+	}{
+		{"untouched", nil, `{"tag_name":"v1.0.0","description":"note"}`},
+		{"removed", []string{}, `{"tag_name":"v1.0.0","description":"note","milestones":[]}`},
+		{"set", []string{"1.0"}, `{"tag_name":"v1.0.0","description":"note","milestones":["1.0"]}`},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, endpoint := newFakeGitLab(t, map[string]string{"PUT /projects/42/releases/v1.0.0": `{}`})
+			client := NewGitlabClient("token", endpoint, "42", "")
+
+			err := client.UpdateTagRelease(app.Release{Name: "v1.0.0", Description: "note", Milestones: tc.milestones})
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.body, fake.lastBody(http.MethodPut, "/projects/42/releases/v1.0.0"))
+		})
+	}
+}
+
+func TestRetrieveCommitRefsBySHA_EscapesRef(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/repository/commits/release%2F2024.1/refs": `[{"name":"main"}]`,
+	})
+	client := NewGitlabClient("token", endpoint, "42", "")
+
+	refs, err := client.RetrieveCommitRefsBySHA("release/2024.1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []app.CommitRef{{Name: "main"}}, refs)
+	assert.Equal(t, 1, fake.count(http.MethodGet, "/projects/42/repository/commits/release%2F2024.1/refs"))
+}
+
+func TestRetrieveTag(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/repository/tags/release%2F2024.1": `{"name":"release/2024.1","commit":{"id":"abc"}}`,
+	})
+	client := NewGitlabClient("token", endpoint, "42", "")
+
+	tag, err := client.RetrieveTag("release/2024.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "release/2024.1", tag.Name)
+	assert.Equal(t, "abc", tag.Commit.ID)
+
+	_, err = client.RetrieveTag("v9.9.9")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func TestProjectPath_IsResolvedOnce(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/mygroup%2Fsubgroup%2Fproject": `{"id":42,"web_url":"https://gitlab.example.com/mygroup/subgroup/project"}`,
+		"GET /projects/42/repository/tags":           `[]`,
+	})
+	client := NewGitlabClient("token", endpoint, "mygroup/subgroup/project", "")
+
+	repo, err := client.RetrieveRepo()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, repo.ID)
+
+	for i := 0; i < 2; i++ {
+		pg := app.Pagination{}
+		pg.SetDefaults()
+		_, err = client.RetrieveTags(&pg)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, fake.count(http.MethodGet, "/projects/mygroup%2Fsubgroup%2Fproject"))
+	assert.Equal(t, 2, fake.count(http.MethodGet, "/projects/42/repository/tags"))
+}
+
+func TestProjectPath_NotFound(t *testing.T) {
+	_, endpoint := newFakeGitLab(t, map[string]string{})
+	client := NewGitlabClient("token", endpoint, "mygroup/missing", "")
+
+	pg := app.Pagination{}
+	pg.SetDefaults()
+	_, err := client.RetrieveTags(&pg)
+	assert.True(t, errors.IsNotFound(err))
+	assert.Contains(t, err.Error(), `"mygroup/missing" not found`)
+}
+
+func TestPublish_TagsWithSpecialCharacters(t *testing.T) {
+	tcs := []struct {
+		tag  string
+		path string
+	}{
+		{"release/2024.2", "/projects/42/releases/release%2F2024.2"},
+		{"v2.0+build.7", "/projects/42/releases/v2.0%2Bbuild.7"},
+		{"версия-2", "/projects/42/releases/%D0%B2%D0%B5%D1%80%D1%81%D0%B8%D1%8F-2"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.tag, func(t *testing.T) {
+			tags, _ := json.Marshal([]app.Tag{
+				{Name: tc.tag, Commit: app.Commit{ID: "b2"}, Release: app.Release{Name: tc.tag}},
+				{Name: "previous", Commit: app.Commit{ID: "b1"}},
+			})
+			fake, endpoint := newFakeGitLab(t, map[string]string{
+				"GET /projects/group%2Fproject":               `{"id":42}`,
+				"GET /projects/42/repository/tags":            string(tags),
+				"GET /projects/42/repository/commits/b2/refs": `[{"name":"main"}]`,
+				"GET /projects/42/repository/commits/b1/refs": `[{"name":"main"}]`,
+				"GET " + tc.path:                              `{"description":"Intro\n<!-- rlsnote:start -->\nold\n<!-- rlsnote:end -->\nOutro"}`,
+				"PUT " + tc.path:                              `{}`,
+			})
+			svc := app.NewGitLabService(NewGitlabClient("token", endpoint, "group/project", ""), app.Config{
+				TargetBranch:   "main",
+				TargetTagRegex: ".*",
+			})
+
+			latestTags, err := svc.RetrieveTwoLatestTags()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.tag, latestTags[0].Name)
+
+			result, err := svc.Publish(latestTags[0], "note", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, app.PublishUpdated, result.Status)
+			assert.Equal(t, 1, fake.count(http.MethodPut, tc.path))
+			assert.Contains(t, fake.lastBody(http.MethodPut, tc.path), `"description":"Intro\n\u003c!-- rlsnote:start --\u003e\nnote\n\u003c!-- rlsnote:end --\u003e\nOutro"`)
+		})
+	}
+}
+
+func TestPublish_SkipsUnchangedDescription(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/releases/v1.0": `{"tag_name":"v1.0","description":"Intro\r\n<!-- rlsnote:start -->\r\nnote  \r\n<!-- rlsnote:end -->\r\n","milestones":[{"title":"17.1"}]}`,
+	})
+	svc := app.NewGitLabService(NewGitlabClient("token", endpoint, "42", ""), app.Config{})
+
+	result, err := svc.Publish(app.Tag{Name: "v1.0", Release: app.Release{Name: "v1.0"}}, "note\n", []string{"17.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, app.PublishUnchanged, result.Status)
+	assert.Equal(t, 0, fake.count(http.MethodPut, "/projects/42/releases/v1.0"))
+}
+
+func TestWikiPage_EscapesSlug(t *testing.T) {
+	fake, endpoint := newFakeGitLab(t, map[string]string{
+		"GET /projects/42/wikis/Releases%2Frelease%2F2024.1": `{"slug":"Releases/release/2024.1","title":"release/2024.1","content":"note"}`,
+		"PUT /projects/42/wikis/Releases%2Frelease%2F2024.1": `{}`,
+	})
+	client := NewGitlabClient("token", endpoint, "42", "")
+
+	page, err := client.RetrieveWikiPage("Releases/release/2024.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "note", page.Content)
+
+	err = client.UpdateWikiPage(page.Slug, app.WikiPage{Title: "Releases/release/2024.1", Content: "new note"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.count(http.MethodPut, "/projects/42/wikis/Releases%2Frelease%2F2024.1"))
+
+	_, err = client.RetrieveWikiPage("Releases/missing")
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitLab-rls-note/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryStore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "history.jsonl")
+	history := NewHistoryStore(file)
+
+	entries, err := history.List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	created := app.HistoryEntry{
+		RunID:       "run-1",
+		ProjectID:   "42",
+		Tag:         "v1.0.0",
+		Timestamp:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Status:      app.PublishCreated,
+		Description: "note\nwith lines",
+		ToolVersion: "1.2.0",
+	}
+	updated := app.HistoryEntry{
+		RunID:               "run-2",
+		ProjectID:           "42",
+		Tag:                 "v1.0.0",
+		Timestamp:           time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC),
+		Status:              app.PublishUpdated,
+		PreviousDescription: "note\nwith lines",
+		Description:         "new note",
+	}
+	assert.NoError(t, history.Append(created))
+	assert.NoError(t, history.Append(updated))
+
+	entries, err = history.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []app.HistoryEntry{created, updated}, entries)
+}
+
+func TestHistoryStore_List(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history.jsonl")
+	assert.NoError(t, os.WriteFile(file, []byte(`{"run_id":"run-1","tag":"v1.0.0","status":"created"}`+"\n\n"), 0o644))
+
+	entries, err := NewHistoryStore(file).List()
+	assert.NoError(t, err)
+	assert.Equal(t, []app.HistoryEntry{{RunID: "run-1", Tag: "v1.0.0", Status: app.PublishCreated}}, entries)
+
+	assert.NoError(t, os.WriteFile(file, []byte("not json\n"), 0o644))
+	_, err = NewHistoryStore(file).List()
+	assert.Error(t, err)
+}
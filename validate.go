@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitLab-rls-note/app"
+	"gitLab-rls-note/pkg/errors"
+	"gitLab-rls-note/store"
+)
+
+// validateConfig checks the options and loads the config files. GitLab is
+// only called to resolve a namespaced project path.
+func validateConfig(env envConfig) error {
+	var missing []string
+	for name, value := range map[string]string{
+		"GITLAB_PERSONAL_TOKEN": env.PersonalToken,
+		"GITLAB_API_ENDPOINT":   env.APIEndpoint,
+		"GITLAB_PROJECT_ID":     env.ProjectID,
+	} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("Missing %s.", strings.Join(missing, ", "))
+	}
+
+	if _, err := regexp.Compile(env.TargetTagRegex); err != nil {
+		return errors.Wrap(err, "Invalid TARGET_TAG_REGEX")
+	}
+	componentRules, err := loadComponentRules(env)
+	if err != nil {
+		return err
+	}
+	if _, err := loadAssetLinks(env.AssetLinksFiles); err != nil {
+		return err
+	}
+	if err := app.ValidateMarkerFallback(env.MarkerFallback); err != nil {
+		return err
+	}
+	if _, err := newContentService(env, componentRules, ""); err != nil {
+		return err
+	}
+
+	publishersCfg, err := loadPublishersConfig(env)
+	if err != nil {
+		return err
+	}
+	client := newGitLabClient(env)
+	gitLabSvc := newGitLabService(env, client, componentRules)
+	if _, err := newPublishers(publishersCfg, env, client, gitLabSvc, app.Tag{}); err != nil {
+		return err
+	}
+
+	if !store.IsProjectID(env.ProjectID) {
+		if _, err := resolveProject(gitLabSvc); err != nil {
+			return err
+		}
+	}
+	return nil
+}